@@ -0,0 +1,433 @@
+// Package devtotest provides an in-memory fake of the Forem API endpoints
+// that hudevto uses, so that the whole status/diff/push pipeline can be
+// tested without talking to dev.to.
+//
+// Like on DEV, the front matter at the top of body_markdown takes precedence
+// over the other fields of the article.
+package devtotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Article is an article as stored by the fake server.
+type Article struct {
+	ID           int
+	Title        string
+	Description  string
+	BodyMarkdown string
+	Published    bool
+	Tags         []string
+	Series       string
+	CanonicalURL string
+	CoverImage   string
+	Slug         string
+	PublishedAt  time.Time
+}
+
+// Request is a request received by the fake server.
+type Request struct {
+	Method string
+	Path   string
+}
+
+// Server is the fake Forem API. Use URL as the base URL of the client.
+type Server struct {
+	*httptest.Server
+
+	// APIKey is the API key that the requests must carry in the api-key
+	// header.
+	APIKey string
+
+	// Username is used to build the article URLs.
+	Username string
+
+	mu         sync.Mutex
+	articles   map[int]*Article
+	nextID     int
+	throttle   int
+	retryAfter time.Duration
+	requests   []Request
+}
+
+// NewServer starts a fake Forem API. It is closed when the test ends.
+func NewServer(tb testing.TB) *Server {
+	s := &Server{
+		APIKey:   "test-api-key",
+		Username: "tester",
+		articles: make(map[int]*Article),
+		nextID:   1000,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	tb.Cleanup(s.Close)
+	return s
+}
+
+// AddArticle stores an article as if it had been created on DEV. When
+// art.ID is 0, an ID is picked. The stored article is returned.
+func (s *Server) AddArticle(art Article) Article {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if art.ID == 0 {
+		art.ID = s.nextID
+	}
+	s.nextID = max(s.nextID, art.ID+1)
+	if art.Slug == "" {
+		art.Slug = slugify(art.Title) + "-" + strconv.FormatInt(int64(art.ID), 36)
+	}
+	s.articles[art.ID] = &art
+	return art
+}
+
+// Article returns the stored article with the given ID.
+func (s *Server) Article(id int) (Article, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	art, ok := s.articles[id]
+	if !ok {
+		return Article{}, false
+	}
+	return *art, true
+}
+
+// Articles returns all the stored articles sorted by ID.
+func (s *Server) Articles() []Article {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var arts []Article
+	for _, art := range s.articles {
+		arts = append(arts, *art)
+	}
+	sort.Slice(arts, func(i, j int) bool { return arts[i].ID < arts[j].ID })
+	return arts
+}
+
+// Throttle makes the next n requests fail with 429 Too Many Requests. The
+// Retry-After header is set to retryAfter, rounded up to the second.
+func (s *Server) Throttle(n int, retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.throttle = n
+	s.retryAfter = retryAfter
+}
+
+// Requests returns the requests received so far.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// URLOf returns the URL of the article as DEV would show it.
+func (s *Server) URLOf(art Article) string {
+	return s.URL + "/" + s.Username + "/" + art.Slug
+}
+
+var articlePath = regexp.MustCompile(`^/api/articles/(\d+)$`)
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path})
+
+	if s.throttle > 0 {
+		s.throttle--
+		w.Header().Set("Retry-After", strconv.Itoa(int((s.retryAfter+time.Second-1)/time.Second)))
+		writeError(w, http.StatusTooManyRequests, "Rate limit reached, try again in 30 seconds")
+		return
+	}
+
+	if r.Header.Get("api-key") != s.APIKey {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/articles/me/published":
+		s.list(w, r, true)
+	case r.Method == "GET" && r.URL.Path == "/api/articles/me/unpublished":
+		s.list(w, r, false)
+	case r.Method == "GET" && articlePath.MatchString(r.URL.Path):
+		s.get(w, r)
+	case r.Method == "POST" && r.URL.Path == "/api/articles":
+		s.createOrUpdate(w, r, nil)
+	case r.Method == "PUT" && articlePath.MatchString(r.URL.Path):
+		id, _ := strconv.Atoi(articlePath.FindStringSubmatch(r.URL.Path)[1])
+		art, ok := s.articles[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, "not found")
+			return
+		}
+		s.createOrUpdate(w, r, art)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) list(w http.ResponseWriter, r *http.Request, published bool) {
+	var ids []int
+	for id, art := range s.articles {
+		if art.Published == published {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ids)))
+
+	perPage := 30
+	if v, err := strconv.Atoi(r.URL.Query().Get("per_page")); err == nil && v > 0 {
+		perPage = v
+	}
+	page := 1
+	if v, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && v > 0 {
+		page = v
+	}
+
+	listed := []map[string]any{}
+	for i := (page - 1) * perPage; i < len(ids) && i < page*perPage; i++ {
+		art := s.articles[ids[i]]
+		m := s.toJSON(art)
+		m["tag_list"] = art.Tags
+		m["body_markdown"] = art.BodyMarkdown
+		listed = append(listed, m)
+	}
+	writeJSON(w, http.StatusOK, listed)
+}
+
+// Like on DEV, only published articles can be fetched by ID.
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(articlePath.FindStringSubmatch(r.URL.Path)[1])
+	art, ok := s.articles[id]
+	if !ok || !art.Published {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	m := s.toJSON(art)
+	m["tag_list"] = strings.Join(art.Tags, ", ")
+	m["tags"] = art.Tags
+	m["body_markdown"] = art.BodyMarkdown
+	writeJSON(w, http.StatusOK, m)
+}
+
+type articleReq struct {
+	Article struct {
+		Title          *string  `json:"title"`
+		BodyMarkdown   *string  `json:"body_markdown"`
+		Published      *bool    `json:"published"`
+		Series         *string  `json:"series"`
+		MainImage      *string  `json:"main_image"`
+		CanonicalURL   *string  `json:"canonical_url"`
+		Description    *string  `json:"description"`
+		Tags           []string `json:"tags"`
+		OrganizationID *int     `json:"organization_id"`
+	} `json:"article"`
+}
+
+// Creates an article when existing is nil, updates existing otherwise.
+func (s *Server) createOrUpdate(w http.ResponseWriter, r *http.Request, existing *Article) {
+	var req articleReq
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "param is missing or the value is empty: article")
+		return
+	}
+
+	art := Article{}
+	if existing != nil {
+		art = *existing
+	}
+	fields := req.Article
+	if fields.Title != nil {
+		art.Title = *fields.Title
+	}
+	if fields.BodyMarkdown != nil {
+		art.BodyMarkdown = *fields.BodyMarkdown
+	}
+	if fields.Published != nil {
+		art.Published = *fields.Published
+	}
+	if fields.Series != nil {
+		art.Series = *fields.Series
+	}
+	if fields.MainImage != nil {
+		art.CoverImage = *fields.MainImage
+	}
+	if fields.CanonicalURL != nil {
+		art.CanonicalURL = *fields.CanonicalURL
+	}
+	if fields.Description != nil {
+		art.Description = *fields.Description
+	}
+	if fields.Tags != nil {
+		art.Tags = fields.Tags
+	}
+
+	if err := applyFrontMatter(&art); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed: "+err.Error())
+		return
+	}
+	if err := s.validate(art); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "Validation failed: "+err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if existing == nil {
+		status = http.StatusCreated
+		art.ID = s.nextID
+		s.nextID++
+		art.Slug = slugify(art.Title) + "-" + strconv.FormatInt(int64(art.ID), 36)
+	}
+	if !art.Published {
+		art.Slug = strings.SplitN(art.Slug, "-temp-slug-", 2)[0] + "-temp-slug-" + strconv.Itoa(art.ID)
+	} else {
+		art.Slug = strings.SplitN(art.Slug, "-temp-slug-", 2)[0]
+	}
+	s.articles[art.ID] = &art
+
+	m := s.toJSON(&art)
+	m["tag_list"] = strings.Join(art.Tags, ", ")
+	m["tags"] = art.Tags
+	m["body_markdown"] = art.BodyMarkdown
+	writeJSON(w, status, m)
+}
+
+var tagRegex = regexp.MustCompile(`^[a-z0-9]+$`)
+
+func (s *Server) validate(art Article) error {
+	if strings.TrimSpace(art.Title) == "" {
+		return fmt.Errorf("Title can't be blank")
+	}
+	if len(art.Tags) > 4 {
+		return fmt.Errorf("Tag list exceed the maximum of 4 tags")
+	}
+	for _, tag := range art.Tags {
+		if !tagRegex.MatchString(tag) {
+			return fmt.Errorf("Tag %q contains non-alphanumeric characters", tag)
+		}
+	}
+	for _, other := range s.articles {
+		if other.ID == art.ID {
+			continue
+		}
+		if art.CanonicalURL != "" && other.CanonicalURL == art.CanonicalURL {
+			return fmt.Errorf("Canonical url has already been taken")
+		}
+		if other.BodyMarkdown == art.BodyMarkdown {
+			return fmt.Errorf("Body markdown has already been taken")
+		}
+	}
+	return nil
+}
+
+// Like DEV, the front matter at the top of body_markdown overrides the other
+// fields.
+func applyFrontMatter(art *Article) error {
+	if !strings.HasPrefix(art.BodyMarkdown, "---\n") {
+		return nil
+	}
+	end := strings.Index(art.BodyMarkdown[4:], "\n---")
+	if end == -1 {
+		return nil
+	}
+
+	var fm struct {
+		Title        *string `yaml:"title"`
+		Description  *string `yaml:"description"`
+		Published    *bool   `yaml:"published"`
+		Tags         *string `yaml:"tags"`
+		Series       *string `yaml:"series"`
+		CanonicalURL *string `yaml:"canonical_url"`
+		CoverImage   *string `yaml:"cover_image"`
+	}
+	if err := yaml.Unmarshal([]byte(art.BodyMarkdown[4:4+end]), &fm); err != nil {
+		return fmt.Errorf("(<unknown>): %s", err)
+	}
+	if fm.Title != nil {
+		art.Title = *fm.Title
+	}
+	if fm.Description != nil {
+		art.Description = *fm.Description
+	}
+	if fm.Published != nil {
+		art.Published = *fm.Published
+	}
+	if fm.Tags != nil {
+		art.Tags = nil
+		for _, tag := range strings.Split(*fm.Tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				art.Tags = append(art.Tags, tag)
+			}
+		}
+	}
+	if fm.Series != nil {
+		art.Series = *fm.Series
+	}
+	if fm.CanonicalURL != nil {
+		art.CanonicalURL = *fm.CanonicalURL
+	}
+	if fm.CoverImage != nil {
+		art.CoverImage = *fm.CoverImage
+	}
+	return nil
+}
+
+func (s *Server) toJSON(art *Article) map[string]any {
+	m := map[string]any{
+		"type_of":       "article",
+		"id":            art.ID,
+		"title":         art.Title,
+		"description":   art.Description,
+		"published":     art.Published,
+		"slug":          art.Slug,
+		"path":          "/" + s.Username + "/" + art.Slug,
+		"url":           s.URLOf(*art),
+		"canonical_url": s.URLOf(*art),
+		"user":          map[string]any{"username": s.Username},
+	}
+	if art.CanonicalURL != "" {
+		m["canonical_url"] = art.CanonicalURL
+	}
+	if art.CoverImage != "" {
+		m["cover_image"] = art.CoverImage
+	}
+	if art.Series != "" {
+		m["series"] = art.Series
+	}
+	if !art.PublishedAt.IsZero() {
+		m["published_at"] = art.PublishedAt.Format(time.RFC3339)
+	}
+	return m
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]any{"error": msg, "status": status})
+}
+
+var nonAlphaNum = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slugify(title string) string {
+	return strings.Trim(nonAlphaNum.ReplaceAllString(strings.ToLower(title), "-"), "-")
+}
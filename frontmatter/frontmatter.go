@@ -0,0 +1,201 @@
+// Package frontmatter edits the front matter of Hugo content files. The three
+// formats supported by Hugo are recognized:
+//
+//	---               +++               {
+//	title: "Foo"      title = "Foo"       "title": "Foo"
+//	---               +++               }
+//
+// Edits are made in place on the text of the front matter rather than by
+// decoding and re-encoding it, which means that comments, key order, quoting
+// and indentation are kept as they are.
+package frontmatter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Format is one of the front matter formats supported by Hugo.
+type Format string
+
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	JSON Format = "json"
+)
+
+// Field is a top-level front matter key along with its value. The value can
+// be a string, a bool or an int.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// Document is a content file split into its front matter and the rest.
+type Document struct {
+	Format Format
+
+	// open is the opening delimiter, e.g. "---\n", and is empty for JSON.
+	open string
+	// fm is the front matter without the delimiters. For JSON, it is the
+	// whole object, including the braces.
+	fm string
+	// rest is the closing delimiter (except for JSON) and the content.
+	rest string
+}
+
+// Parse detects the front matter format and splits the content file. An error
+// is returned when the file has no front matter.
+func Parse(content []byte) (*Document, error) {
+	s := string(content)
+	switch {
+	case strings.HasPrefix(s, "---"):
+		return parseDelimited(s, YAML, "---")
+	case strings.HasPrefix(s, "+++"):
+		return parseDelimited(s, TOML, "+++")
+	case strings.HasPrefix(s, "{"):
+		end, err := scanJSONValue(s, 0)
+		if err != nil {
+			return nil, fmt.Errorf("JSON front matter: %w", err)
+		}
+		return &Document{Format: JSON, fm: s[:end], rest: s[end:]}, nil
+	}
+	return nil, fmt.Errorf("front matter not found, expected the file to start with ---, +++ or {")
+}
+
+// The front matter is between the line containing the opening delimiter and
+// the next line containing the same delimiter.
+func parseDelimited(s string, format Format, delim string) (*Document, error) {
+	firstLineEnd := strings.IndexByte(s, '\n')
+	if firstLineEnd == -1 || strings.TrimSpace(s[:firstLineEnd]) != delim {
+		return nil, fmt.Errorf("front matter not found, expected %s on its own line", delim)
+	}
+	open := s[:firstLineEnd+1]
+
+	offset := len(open)
+	for offset < len(s) {
+		lineEnd := strings.IndexByte(s[offset:], '\n')
+		line := s[offset:]
+		if lineEnd != -1 {
+			line = s[offset : offset+lineEnd]
+		}
+		if strings.TrimSpace(line) == delim {
+			return &Document{Format: format, open: open, fm: s[len(open):offset], rest: s[offset:]}, nil
+		}
+		if lineEnd == -1 {
+			break
+		}
+		offset += lineEnd + 1
+	}
+	return nil, fmt.Errorf("front matter is not closed, expected a closing %s", delim)
+}
+
+// Set adds or updates a top-level key. When the key already exists (the
+// comparison is case-insensitive like in Hugo), its spelling, its quoting
+// style and its trailing comment are kept. Otherwise, the key is added after
+// the last top-level key.
+func (d *Document) Set(key string, value any) error {
+	switch value.(type) {
+	case string, bool, int:
+	default:
+		return fmt.Errorf("unsupported type %T for key %s", value, key)
+	}
+
+	var err error
+	switch d.Format {
+	case YAML:
+		d.fm = setYAML(d.fm, key, value)
+	case TOML:
+		d.fm, err = setTOML(d.fm, key, value)
+	case JSON:
+		d.fm, err = setJSON(d.fm, key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s front matter: %w", d.Format, err)
+	}
+	return nil
+}
+
+// Bytes returns the whole content file.
+func (d *Document) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(d.open)
+	b.WriteString(d.fm)
+	b.WriteString(d.rest)
+	return b.Bytes()
+}
+
+// SetFields adds or updates the given top-level keys in the front matter of
+// the content file at path.
+func SetFields(path string, fields ...Field) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading file: %w", err)
+	}
+
+	doc, err := Parse(content)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for _, field := range fields {
+		if err := doc.Set(field.Key, field.Value); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, doc.Bytes(), info.Mode().Perm())
+}
+
+// splitLines splits s into lines, each line keeping its trailing newline.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// joinLines is the inverse of splitLines. The last line always gets a
+// trailing newline so that the closing delimiter stays on its own line.
+func joinLines(lines []string) string {
+	s := strings.Join(lines, "")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	return s
+}
+
+// lineEnding returns "\r\n" if the line ends with it, "\n" otherwise.
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r\n") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// splitComment splits "value # comment" into "value" and " # comment". The
+// comment char is ignored when inside of a quoted string.
+func splitComment(s string) (string, string) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			value := strings.TrimRight(s[:i], " \t")
+			return value, s[len(value):]
+		}
+	}
+	return s, ""
+}
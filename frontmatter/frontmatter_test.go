@@ -0,0 +1,208 @@
+package frontmatter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maelvls/undent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument_Set(t *testing.T) {
+	tests := []struct {
+		name   string
+		given  string
+		fields []Field
+		expect string
+	}{
+		{
+			name: "yaml: adds missing keys at the end",
+			given: undent.Undent(`
+				---
+				title: "Foo" # The title.
+				tags: [a, b]
+				---
+				Body with a --- line.
+			`),
+			fields: []Field{{"devtoId", 42}, {"devtoPublished", true}, {"devtoUrl", "https://dev.to/foo/bar-1abc"}},
+			expect: undent.Undent(`
+				---
+				title: "Foo" # The title.
+				tags: [a, b]
+				devtoId: 42
+				devtoPublished: true
+				devtoUrl: https://dev.to/foo/bar-1abc
+				---
+				Body with a --- line.
+			`),
+		},
+		{
+			name: "yaml: keeps quoting, key spelling and comments",
+			given: undent.Undent(`
+				---
+				# Managed by hudevto.
+				devtoid: 1 # Don't touch.
+				devtoUrl: 'https://dev.to/old'
+				description: "old"
+				---
+			`),
+			fields: []Field{{"devtoId", 42}, {"devtoUrl", "https://dev.to/it's"}, {"description", "with \"quotes\""}},
+			expect: undent.Undent(`
+				---
+				# Managed by hudevto.
+				devtoid: 42 # Don't touch.
+				devtoUrl: 'https://dev.to/it''s'
+				description: "with \"quotes\""
+				---
+			`),
+		},
+		{
+			name: "yaml: replaces multi-line values and ignores nested keys",
+			given: undent.Undent(`
+				---
+				params:
+				  devtoUrl: nested
+				devtoUrl: >
+				  folded
+				  value
+				draft: false
+				---
+			`),
+			fields: []Field{{"devtoUrl", "needs: quotes"}},
+			expect: undent.Undent(`
+				---
+				params:
+				  devtoUrl: nested
+				devtoUrl: "needs: quotes"
+				draft: false
+				---
+			`),
+		},
+		{
+			name: "yaml: strings that look like other types are quoted",
+			given: undent.Undent(`
+				---
+				---
+			`),
+			fields: []Field{{"a", "true"}, {"b", "123"}, {"c", ""}},
+			expect: undent.Undent(`
+				---
+				a: "true"
+				b: "123"
+				c: ""
+				---
+			`),
+		},
+		{
+			name: "toml: adds missing keys before the first table",
+			given: undent.Undent(`
+				+++
+				title = "Foo" # The title.
+				tags = [
+				  "a",
+				  "b",
+				]
+
+				[params]
+				devtoId = 1
+				+++
+				Body.
+			`),
+			fields: []Field{{"devtoId", 42}, {"devtoUrl", "https://dev.to/foo"}},
+			expect: undent.Undent(`
+				+++
+				title = "Foo" # The title.
+				tags = [
+				  "a",
+				  "b",
+				]
+				devtoId = 42
+				devtoUrl = "https://dev.to/foo"
+
+				[params]
+				devtoId = 1
+				+++
+				Body.
+			`),
+		},
+		{
+			name: "toml: keeps literal strings and comments",
+			given: undent.Undent(`
+				+++
+				devtoUrl = 'https://dev.to/old' # Set by hudevto.
+				devtoPublished = false
+				+++
+			`),
+			fields: []Field{{"devtoUrl", "https://dev.to/new"}, {"devtoPublished", true}},
+			expect: undent.Undent(`
+				+++
+				devtoUrl = 'https://dev.to/new' # Set by hudevto.
+				devtoPublished = true
+				+++
+			`),
+		},
+		{
+			name: "json: updates in place and adds with the same indentation",
+			given: undent.Undent(`
+				{
+				  "title": "Foo, {bar}",
+				  "devtoId": 1,
+				  "tags": ["a", "b"]
+				}
+				Body.
+			`),
+			fields: []Field{{"devtoId", 42}, {"devtoUrl", "https://dev.to/foo"}},
+			expect: undent.Undent(`
+				{
+				  "title": "Foo, {bar}",
+				  "devtoId": 42,
+				  "tags": ["a", "b"],
+				  "devtoUrl": "https://dev.to/foo"
+				}
+				Body.
+			`),
+		},
+		{
+			name:   "json: adds to a one-line object twice",
+			given:  "{\"title\": \"Foo\"}\nBody.\n",
+			fields: []Field{{"devtoId", 42}, {"devtoPublished", false}, {"devtoId", 43}},
+			expect: "{\"title\": \"Foo\", \"devtoId\": 43, \"devtoPublished\": false}\nBody.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := Parse([]byte(tt.given))
+			require.NoError(t, err)
+			for _, f := range tt.fields {
+				require.NoError(t, doc.Set(f.Key, f.Value))
+			}
+			assert.Equal(t, tt.expect, string(doc.Bytes()))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	_, err := Parse([]byte("No front matter.\n"))
+	assert.EqualError(t, err, "front matter not found, expected the file to start with ---, +++ or {")
+
+	_, err = Parse([]byte("---\ntitle: foo\n"))
+	assert.EqualError(t, err, "front matter is not closed, expected a closing ---")
+
+	doc, err := Parse([]byte("+++\n+++\n"))
+	require.NoError(t, err)
+	assert.Equal(t, TOML, doc.Format)
+}
+
+func TestSetFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.md")
+	require.NoError(t, os.WriteFile(path, []byte("---\ntitle: Foo\n---\n"), 0600))
+
+	err := SetFields(path, Field{"devtoId", 42})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "---\ntitle: Foo\ndevtoId: 42\n---\n", string(content))
+}
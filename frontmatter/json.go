@@ -0,0 +1,163 @@
+package frontmatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type jsonMember struct {
+	key                  string
+	keyStart             int
+	valueStart, valueEnd int
+}
+
+func setJSON(fm, key string, value any) (string, error) {
+	members, err := scanJSONObject(fm)
+	if err != nil {
+		return "", err
+	}
+
+	var encoded string
+	switch v := value.(type) {
+	case bool:
+		encoded = strconv.FormatBool(v)
+	case int:
+		encoded = strconv.Itoa(v)
+	case string:
+		encoded = quoteJSON(v)
+	}
+
+	for _, m := range members {
+		if strings.EqualFold(m.key, key) {
+			return fm[:m.valueStart] + encoded + fm[m.valueEnd:], nil
+		}
+	}
+
+	if len(members) == 0 {
+		open := strings.IndexByte(fm, '{')
+		return fm[:open+1] + "\n  " + quoteJSON(key) + ": " + encoded + "\n" + strings.TrimLeft(fm[open+1:], " \t\r\n"), nil
+	}
+
+	// The new member is indented like the last member. In a compact object,
+	// e.g., {"title":"Foo"}, it is separated by a space.
+	last := members[len(members)-1]
+	indent := fm[strings.LastIndexAny(fm[:last.keyStart], ",{")+1 : last.keyStart]
+	if indent == "" || strings.TrimSpace(indent) != "" {
+		indent = " "
+	}
+	return fm[:last.valueEnd] + "," + indent + quoteJSON(key) + ": " + encoded + fm[last.valueEnd:], nil
+}
+
+// scanJSONObject returns the members of the top-level JSON object.
+func scanJSONObject(s string) ([]jsonMember, error) {
+	i := skipSpace(s, 0)
+	if i >= len(s) || s[i] != '{' {
+		return nil, fmt.Errorf("expected an object")
+	}
+	i = skipSpace(s, i+1)
+	if i < len(s) && s[i] == '}' {
+		return nil, nil
+	}
+
+	var members []jsonMember
+	for {
+		if i >= len(s) || s[i] != '"' {
+			return nil, fmt.Errorf("expected a key at offset %d", i)
+		}
+		keyEnd, err := scanJSONValue(s, i)
+		if err != nil {
+			return nil, err
+		}
+		var key string
+		if err := json.Unmarshal([]byte(s[i:keyEnd]), &key); err != nil {
+			return nil, fmt.Errorf("invalid key at offset %d: %w", i, err)
+		}
+		m := jsonMember{key: key, keyStart: i}
+
+		i = skipSpace(s, keyEnd)
+		if i >= len(s) || s[i] != ':' {
+			return nil, fmt.Errorf("expected ':' at offset %d", i)
+		}
+		m.valueStart = skipSpace(s, i+1)
+		m.valueEnd, err = scanJSONValue(s, m.valueStart)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+
+		i = skipSpace(s, m.valueEnd)
+		if i >= len(s) {
+			return nil, fmt.Errorf("object is not closed")
+		}
+		switch s[i] {
+		case ',':
+			i = skipSpace(s, i+1)
+		case '}':
+			return members, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or '}' at offset %d", i)
+		}
+	}
+}
+
+// scanJSONValue returns the offset right after the JSON value that starts at
+// the given offset.
+func scanJSONValue(s string, start int) (int, error) {
+	depth := 0
+	inString := false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString && c == '\\':
+			i++
+		case inString && c == '"':
+			inString = false
+			if depth == 0 {
+				return i + 1, nil
+			}
+		case inString:
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth == 0 && i > start {
+				// End of a number, true, false or null that is the last
+				// member of the object, e.g., {"devtoId": 42}.
+				return i, nil
+			}
+			depth--
+			if depth == 0 {
+				return i + 1, nil
+			}
+			if depth < 0 {
+				return 0, fmt.Errorf("unexpected '%c' at offset %d", c, i)
+			}
+		case depth == 0 && (c == ',' || c == ' ' || c == '\t' || c == '\r' || c == '\n'):
+			// End of a number, true, false or null.
+			return i, nil
+		}
+	}
+	if depth == 0 && !inString && start < len(s) {
+		return len(s), nil
+	}
+	return 0, fmt.Errorf("value starting at offset %d is not terminated", start)
+}
+
+func skipSpace(s string, i int) int {
+	for i < len(s) && strings.IndexByte(" \t\r\n", s[i]) != -1 {
+		i++
+	}
+	return i
+}
+
+func quoteJSON(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,118 @@
+package frontmatter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A bare TOML key, e.g., "devtoId = 42". Dotted and quoted keys aren't
+// recognized.
+var tomlKey = regexp.MustCompile(`^([ \t]*)([A-Za-z0-9_-]+)([ \t]*=[ \t]*)(.*)$`)
+
+func setTOML(fm, key string, value any) (string, error) {
+	lines := splitLines(fm)
+
+	// Only the keys that appear before the first table header, e.g.,
+	// "[params]", are top-level keys.
+	lastTopLevel := -1
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		content := strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(strings.TrimSpace(content), "[") {
+			break
+		}
+		m := tomlKey.FindStringSubmatch(content)
+		if m == nil {
+			continue
+		}
+
+		// The value may span several lines, e.g., an array or a multi-line
+		// string.
+		end, err := tomlValueEnd(lines, i, len(m[1])+len(m[2])+len(m[3]))
+		if err != nil {
+			return "", fmt.Errorf("key %s: %w", m[2], err)
+		}
+
+		if !strings.EqualFold(m[2], key) {
+			lastTopLevel = end - 1
+			i = end - 1
+			continue
+		}
+
+		oldValue, comment := splitComment(m[4])
+		if end > i+1 {
+			comment = ""
+		}
+		newLine := m[1] + m[2] + m[3] + formatTOML(value, oldValue) + comment + lineEnding(line)
+		return joinLines(append(lines[:i], append([]string{newLine}, lines[end:]...)...)), nil
+	}
+
+	eol := "\n"
+	if len(lines) > 0 {
+		eol = lineEnding(lines[0])
+	}
+	newLine := key + " = " + formatTOML(value, "") + eol
+	insertAt := lastTopLevel + 1
+	return joinLines(append(lines[:insertAt], append([]string{newLine}, lines[insertAt:]...)...)), nil
+}
+
+// tomlValueEnd returns the index of the line after the last line of the value
+// starting at the given line and column.
+func tomlValueEnd(lines []string, lineIdx, col int) (int, error) {
+	depth := 0
+	var quote string
+	for i := lineIdx; i < len(lines); i++ {
+		s := lines[i]
+		start := 0
+		if i == lineIdx {
+			start = col
+		}
+		for j := start; j < len(s); j++ {
+			switch {
+			case quote != "":
+				if quote[0] == '"' && s[j] == '\\' {
+					j++
+				} else if strings.HasPrefix(s[j:], quote) {
+					j += len(quote) - 1
+					quote = ""
+				}
+			case strings.HasPrefix(s[j:], `"""`) || strings.HasPrefix(s[j:], `'''`):
+				quote = s[j : j+3]
+				j += 2
+			case s[j] == '"' || s[j] == '\'':
+				quote = s[j : j+1]
+			case s[j] == '[' || s[j] == '{':
+				depth++
+			case s[j] == ']' || s[j] == '}':
+				depth--
+			case s[j] == '#':
+				j = len(s)
+			}
+		}
+		if depth == 0 && (quote == "" || len(quote) == 1) {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("value is not terminated")
+}
+
+// formatTOML encodes the value as a TOML value. A literal string ('...') is
+// kept as a literal string when the new value can be represented as one.
+func formatTOML(value any, oldValue string) string {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	}
+
+	s := value.(string)
+	if strings.HasPrefix(oldValue, "'") && !strings.HasPrefix(oldValue, "'''") && !strings.ContainsAny(s, "'\n\r") {
+		return "'" + s + "'"
+	}
+
+	// JSON strings are also valid TOML basic strings.
+	return quoteJSON(s)
+}
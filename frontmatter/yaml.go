@@ -0,0 +1,87 @@
+package frontmatter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A top-level YAML key, e.g., "devtoId: 42". Keys that are indented belong to a
+// nested mapping and are ignored.
+var yamlKey = regexp.MustCompile(`^([A-Za-z0-9_-]+)[ \t]*:([ \t].*|)$`)
+
+func setYAML(fm, key string, value any) string {
+	lines := splitLines(fm)
+
+	for i, line := range lines {
+		content := strings.TrimRight(line, "\r\n")
+		m := yamlKey.FindStringSubmatch(content)
+		if m == nil || !strings.EqualFold(m[1], key) {
+			continue
+		}
+
+		oldValue, comment := splitComment(strings.TrimSpace(m[2]))
+
+		// The old value may span several lines, e.g., a list or a block
+		// scalar. These lines are replaced too.
+		end := i + 1
+		for j := i + 1; j < len(lines); j++ {
+			next := strings.TrimRight(lines[j], "\r\n")
+			if strings.TrimSpace(next) == "" {
+				continue
+			}
+			if next[0] != ' ' && next[0] != '\t' && !strings.HasPrefix(next, "- ") && next != "-" {
+				break
+			}
+			end = j + 1
+		}
+		if end > i+1 {
+			// The comment is only kept for single-line values.
+			comment = ""
+		}
+
+		newLine := m[1] + ": " + formatYAML(value, oldValue) + comment + lineEnding(line)
+		return joinLines(append(lines[:i], append([]string{newLine}, lines[end:]...)...))
+	}
+
+	eol := "\n"
+	if len(lines) > 0 {
+		eol = lineEnding(lines[0])
+	}
+	return joinLines(append(lines, key+": "+formatYAML(value, "")+eol))
+}
+
+// formatYAML encodes the value as a YAML scalar. The quoting style of the old
+// value is kept when possible. Strings that don't need quoting are left
+// unquoted unless the old value was quoted.
+func formatYAML(value any, oldValue string) string {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	}
+
+	s := value.(string)
+	switch {
+	case strings.HasPrefix(oldValue, "'") && !strings.ContainsAny(s, "\n"):
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	case strings.HasPrefix(oldValue, `"`):
+		// JSON strings are also valid YAML double-quoted scalars.
+		return quoteJSON(s)
+	case isPlainYAML(s):
+		return s
+	default:
+		return quoteJSON(s)
+	}
+}
+
+var yamlPlainSafe = regexp.MustCompile(`^[A-Za-z0-9_./~+-][A-Za-z0-9_./~+:=?&%@,()-]*$`)
+var yamlNonString = regexp.MustCompile(`^(?i:true|false|yes|no|on|off|y|n|null|~|[-+]?[0-9][0-9_.:eE+-]*|[-+]?\.inf|\.nan)$`)
+
+// isPlainYAML tells whether the string can be written without quotes and
+// still be read back as the same string. It is conservative: a string that
+// would need a closer look is reported as not plain.
+func isPlainYAML(s string) bool {
+	return yamlPlainSafe.MatchString(s) && !yamlNonString.MatchString(s) && !strings.Contains(s, ": ")
+}
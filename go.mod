@@ -5,7 +5,6 @@ go 1.23.5
 toolchain go1.24.2
 
 require (
-	github.com/MakeNowJust/heredoc v1.0.0
 	github.com/VictorAvelar/devto-api-go v1.0.0
 	github.com/charmbracelet/fang v0.2.0
 	github.com/gohugoio/hugo v0.147.7
@@ -13,8 +12,11 @@ require (
 	github.com/mgutz/ansi v0.0.0-20200706080929-d51e80ef957d
 	github.com/sergi/go-diff v1.3.1
 	github.com/sethgrid/gencurl v0.0.0-20161025011400-a3af93c1aba4
+	github.com/spf13/afero v1.14.0
 	github.com/spf13/cobra v1.9.1
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/net v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -111,14 +113,12 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/rogpeppe/go-internal v1.14.1 // indirect
 	github.com/schollz/closestmatch v2.1.0+incompatible
-	github.com/spf13/afero v1.14.0 // indirect
 	github.com/spf13/cast v1.8.0 // indirect
 	github.com/spf13/pflag v1.0.6 // indirect
 	github.com/tdewolff/minify/v2 v2.23.5 // indirect
 	github.com/tdewolff/parse/v2 v2.8.1 // indirect
 	github.com/yuin/goldmark v1.7.11
 	golang.org/x/image v0.27.0 // indirect
-	golang.org/x/net v0.40.0 // indirect
 	golang.org/x/sync v0.14.0 // indirect
 	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/term v0.32.0
@@ -126,5 +126,4 @@ require (
 	golang.org/x/tools v0.33.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -38,3 +38,9 @@ func Infof(format string, a ...interface{}) {
 	_, _ = fmt.Fprintf(os.Stderr, "%s: ", Yel("info"))
 	_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
 }
+
+// Prints to stderr.
+func Warnf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(os.Stderr, "%s: ", Yel("warning"))
+	_, _ = fmt.Fprintf(os.Stderr, format+"\n", a...)
+}
@@ -1,54 +1,88 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net/http"
 	"os"
-	"path"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
-	"github.com/MakeNowJust/heredoc"
 	"github.com/VictorAvelar/devto-api-go/devto"
 	"github.com/charmbracelet/fang"
-	"github.com/gohugoio/hugo/config"
-	"github.com/gohugoio/hugo/config/allconfig"
-	"github.com/gohugoio/hugo/deps"
-	"github.com/gohugoio/hugo/hugofs"
-	"github.com/gohugoio/hugo/hugolib"
-	"github.com/gohugoio/hugo/resources/page"
-	"github.com/schollz/closestmatch"
-	"github.com/sethgrid/gencurl"
 	"github.com/spf13/cobra"
-	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark/ast"
-	"github.com/yuin/goldmark/text"
 
 	"github.com/maelvls/hudevto/logutil"
+	"github.com/maelvls/hudevto/sync"
 	"github.com/maelvls/undent"
 )
 
+// The exit codes of status and diff. Like with 'git diff --exit-code', a CI
+// job can tell "in sync" from "changes to push" from "something is wrong".
+const (
+	exitCodeError   = 1
+	exitCodePending = 2
+)
+
+// exitCodeErr makes hudevto exit with the given code. Nothing is printed since
+// the posts were already reported.
+type exitCodeErr struct {
+	code int
+	msg  string
+}
+
+func (e *exitCodeErr) Error() string { return e.msg }
+
 func main() {
 	rootCmd := mainCmd()
 	err := fang.Execute(context.Background(), rootCmd, fang.WithErrorHandler(func(w io.Writer, styles fang.Styles, err error) {
+		var exitErr *exitCodeErr
+		if errors.As(err, &exitErr) {
+			return
+		}
 		fang.DefaultErrorHandler(w, styles, err)
 	}))
+	var exitErr *exitCodeErr
+	if errors.As(err, &exitErr) {
+		os.Exit(exitErr.code)
+	}
 	if err != nil {
-		os.Exit(1)
+		os.Exit(exitCodeError)
 	}
 }
 
+// planExitCode returns an error with the exit code exitCodeError when a post
+// is in error or has missing images, or exitCodePending when a post needs to
+// be pushed or created.
+func planExitCode(plan *sync.Plan) error {
+	var errored, pending int
+	for _, post := range plan.Posts {
+		switch {
+		case post.Action == sync.ActionError, len(post.MissingImages) > 0:
+			errored++
+		case post.Action == sync.ActionPush, post.Action == sync.ActionCreate:
+			pending++
+		}
+	}
+	switch {
+	case errored > 0:
+		return &exitCodeErr{code: exitCodeError, msg: fmt.Sprintf("%d post(s) in error", errored)}
+	case pending > 0:
+		return &exitCodeErr{code: exitCodePending, msg: fmt.Sprintf("%d post(s) with changes to push", pending)}
+	}
+	return nil
+}
+
 func mainCmd() *cobra.Command {
-	var rootDir, apiKeyFlag string
+	var rootDir, apiKeyFlag, configFlag, environmentFlag, devtoURLFlag string
+	var retryBudget time.Duration
+	var concurrency int
+	var seriesField string
 	cmd := &cobra.Command{
 		Use:   "hudevto",
 		Short: "Synchronize your Hugo posts with your DEV articles.",
@@ -63,14 +97,22 @@ func mainCmd() *cobra.Command {
 		`),
 	}
 	cmd.PersistentFlags().StringVar(&rootDir, "root", "", "Root directory of the Hugo project.")
+	cmd.PersistentFlags().StringVar(&configFlag, "config", "", "Comma-separated list of Hugo config files, relative to --root. Defaults to the first of hugo.toml, hugo.yaml, hugo.json, config.toml, config.yaml and config.json that exists, merged with the config/ directory, like Hugo does.")
+	cmd.PersistentFlags().StringVar(&environmentFlag, "environment", "", "The Hugo environment used to pick the config/<environment>/ directory. Defaults to $HUGO_ENVIRONMENT or to 'production'.")
 	cmd.PersistentFlags().StringVar(&apiKeyFlag, "apikey", "", "The API key for Dev.to. You can also set DEVTO_APIKEY instead.")
+	cmd.PersistentFlags().StringVar(&devtoURLFlag, "devto-url", sync.DefaultBaseURL, "The base URL of the Forem instance to sync with.")
+	cmd.PersistentFlags().DurationVar(&retryBudget, "retry-budget", sync.DefaultRetryBudget, "How long a request to DEV can keep being retried when it is rate limited (429) or when DEV fails with a 5xx. The requests are also paced to stay under DEV's limit of 30 requests per 30 seconds.")
+	cmd.PersistentFlags().IntVar(&concurrency, "concurrency", 1, "Number of posts rendered, compared and pushed at the same time. The output stays in the same order regardless of the concurrency.")
+	cmd.PersistentFlags().StringVar(&seriesField, "series-field", sync.DefaultSeriesField, "The front matter field or the Hugo taxonomy that holds the DEV series of a post.")
 	cmd.PersistentFlags().BoolVar(&logutil.EnableDebug, "debug", false, "Print debug information such as the HTTP requests that are being made in curl format.")
 
-	cmd.AddCommand(statusCmd(), pushCmd(), previewCmd(), diffCmd(), devtoCmd())
+	cmd.AddCommand(statusCmd(), pushCmd(), previewCmd(), diffCmd(), linkCmd(), importCmd(), devtoCmd())
 	return cmd
 }
 
 func statusCmd() *cobra.Command {
+	var create, groupBySeries bool
+	var checkImages string
 	cmd := &cobra.Command{
 		Use:   "status [POST]",
 		Short: "Show the status of each post (or a single post)",
@@ -78,56 +120,83 @@ func statusCmd() *cobra.Command {
 			Shows the status of each post (or of a single post). The status shows
 			whether it is mapped to a DEV article and if a push is required when the
 			Hugo post has changes that are not on DEV yet.
+
+			With --check-images, the images of each post are looked up in the page
+			bundles and in the static directories so that the broken images are
+			found before DEV shows them. With --check-images=online, a HEAD request
+			is also made for each image.
+
+			The exit code is 1 when a post is in error or has missing images, 2 when
+			a post has changes to push (or would be created with --create), and 0
+			when all the posts are in sync with DEV.
 		`),
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			apiKey, err := getApiKey(cmd)
-			if err != nil {
-				return err
-			}
 			var pathToArticle string
 			if len(args) > 0 {
 				pathToArticle = args[0]
 			}
-			rootDir, err := getRootDir(cmd)
+			out, err := newRecordWriter(cmd)
 			if err != nil {
-				return fmt.Errorf("--root: %w", err)
+				return err
 			}
-			rootDir = filepath.Clean(rootDir)
-			return PushArticlesFromHugoToDevto(rootDir, pathToArticle, false, false, true, apiKey)
+			plan, _, err := loadPlan(cmd, pathToArticle, create, false)
+			if err != nil {
+				return err
+			}
+			if err := printStatus(plan, out, groupBySeries); err != nil {
+				return err
+			}
+			return planExitCode(plan)
 		},
 	}
+	addOutputFlag(cmd)
+	cmd.Flags().BoolVar(&create, "create", false, "Show the posts that have no devtoId as posts that will be created on DEV, as 'push --create' would do.")
+	cmd.Flags().BoolVar(&groupBySeries, "group-by-series", false, "Group the posts by DEV series, see --series-field.")
+	cmd.Flags().StringVar(&checkImages, "check-images", "", "Report the images that can't be found. One of 'offline' (the default when no value is given) or 'online', which also makes a HEAD request for each image.")
+	cmd.Flags().Lookup("check-images").NoOptDefVal = sync.ImageCheckOffline
 	return cmd
 }
 
 func pushCmd() *cobra.Command {
+	var create bool
 	cmd := &cobra.Command{
 		Use:   "push [POST]",
 		Short: "Push the given Hugo Markdown post to DEV.",
 		Long: undent.Undent(`
 			Pushes the given Hugo Markdown post to DEV. If no post is given, then
 			all posts are pushed. The post must be a Markdown file, i.e., *.md.
+
+			With --create, the posts that have no devtoId are created on DEV and
+			the new devtoId is written to their front matter.
 		`),
 		Example: undent.Undent(`
 			hudevto push ./content/post-1/index.md
+			hudevto push --create
 		`),
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			apiKey, err := getApiKey(cmd)
-			if err != nil {
-				return fmt.Errorf("while getting API key: %w", err)
-			}
 			var pathToArticle string
 			if len(args) > 0 {
 				pathToArticle = args[0]
 			}
-			rootDir, err := getRootDir(cmd)
+			out, err := newRecordWriter(cmd)
 			if err != nil {
 				return err
 			}
-			return PushArticlesFromHugoToDevto(rootDir, pathToArticle, false, false, false, apiKey)
+			plan, client, err := loadPlan(cmd, pathToArticle, create, true)
+			if err != nil {
+				return err
+			}
+			concurrency, err := cmd.Flags().GetInt("concurrency")
+			if err != nil {
+				return fmt.Errorf("--concurrency: %w", err)
+			}
+			return push(cmd.Context(), plan, sync.Executor{Client: client, Concurrency: concurrency}, out)
 		},
 	}
+	addOutputFlag(cmd)
+	cmd.Flags().BoolVar(&create, "create", false, "Create a DEV article for each post that has no devtoId and whose title doesn't match any of your DEV articles. The new article's ID is written to the post's front matter as devtoId.")
 	return cmd
 }
 
@@ -142,10 +211,6 @@ func previewCmd() *cobra.Command {
 		`),
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			apiKey, err := getApiKey(cmd)
-			if err != nil {
-				return fmt.Errorf("while getting API key: %w", err)
-			}
 			var pathToArticle string
 			if len(args) > 0 {
 				pathToArticle = args[0]
@@ -153,43 +218,174 @@ func previewCmd() *cobra.Command {
 			if len(args) == 0 {
 				return fmt.Errorf("no post given, please provide a post to preview")
 			}
-			rootDir, err := getRootDir(cmd)
+			plan, _, err := loadPlan(cmd, pathToArticle, true, false)
 			if err != nil {
 				return err
 			}
-			return PushArticlesFromHugoToDevto(rootDir, pathToArticle, true, false, true, apiKey)
+			printPreview(plan)
+			return nil
 		},
 	}
 	return cmd
 }
 
 func diffCmd() *cobra.Command {
+	var create bool
 	cmd := &cobra.Command{
 		Use:   "diff [POST]",
 		Short: "Display a diff between the Hugo post and the DEV article.",
 		Long: undent.Undent(`
 			Displays a diff between the Hugo post and the DEV article. It is useful
 			when you want to see what changes will be pushed.
+
+			The exit code is the same as for 'hudevto status': 1 when a post is in
+			error, 2 when there are changes to push, and 0 otherwise.
 		`),
 		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			apiKey, err := getApiKey(cmd)
+			var pathToArticle string
+			if len(args) > 0 {
+				pathToArticle = args[0]
+			}
+			out, err := newRecordWriter(cmd)
+			if err != nil {
+				return err
+			}
+			plan, _, err := loadPlan(cmd, pathToArticle, create, false)
 			if err != nil {
-				return fmt.Errorf("while getting API key: %w", err)
+				return err
 			}
+			if err := printDiff(plan, out); err != nil {
+				return err
+			}
+			return planExitCode(plan)
+		},
+	}
+	addOutputFlag(cmd)
+	cmd.Flags().BoolVar(&create, "create", false, "Also show the posts that have no devtoId as new articles, as 'push --create' would do.")
+	return cmd
+}
 
+func linkCmd() *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "link [POST]",
+		Short: "Map the posts that have no devtoId to your existing DEV articles.",
+		Long: undent.Undent(`
+			Finds a DEV article for each post (or for a single post) that has no
+			devtoId in its front matter. A post is matched to a DEV article when
+			both have the same title, when the article's canonical URL is the
+			post's URL, or, as a last resort, when the article's title is the
+			closest to the post's title and is similar enough.
+
+			The proposed mapping is shown and, once confirmed, the devtoId and
+			devtoPublished fields are written to the front matter of each post.
+			The devtoPublished field is set to the current state of the DEV
+			article.
+		`),
+		Example: undent.Undent(`
+			hudevto link
+			hudevto link ./content/post-1/index.md --yes
+		`),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
 			var pathToArticle string
 			if len(args) > 0 {
 				pathToArticle = args[0]
 			}
+			planner, err := newPlanner(cmd)
+			if err != nil || planner == nil {
+				return err
+			}
+			links, err := planner.ProposeLinks(cmd.Context(), pathToArticle)
+			if err != nil {
+				return err
+			}
+			return link(cmd.InOrStdin(), links, yes)
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Write the proposed mapping without asking for confirmation.")
+	return cmd
+}
 
+func importCmd() *cobra.Command {
+	var section string
+	cmd := &cobra.Command{
+		Use:   "import [ID...]",
+		Short: "Import DEV articles as Hugo posts.",
+		Long: undent.Undent(`
+			Writes the DEV articles with the given IDs as Hugo posts. Without any ID,
+			all your DEV articles that aren't mapped to a post are imported, except
+			the ones whose canonical URL is one of your posts, e.g., the ones created
+			by DEV's RSS importer; use 'hudevto link' for them.
+
+			Each article is written to content/<section>/<slug>/index.md with
+			devtoId, devtoPublished and devtoUrl set so that the next push updates
+			the article instead of creating a new one. The articles that aren't
+			published on DEV are imported as drafts, which push skips until you
+			remove draft: true.
+
+			The Liquid tags are converted to the shortcodes they come from, or to
+			a link for {% twitter %} and {% gist %}. The links to the headings are
+			changed to Hugo's anchor IDs, the images are downloaded into the page
+			bundle, and the URLs of your blog are made relative.
+		`),
+		Example: undent.Undent(`
+			hudevto import
+			hudevto import 365846 365847 --section blog
+		`),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ids []int
+			for _, arg := range args {
+				id, err := strconv.Atoi(arg)
+				if err != nil {
+					return fmt.Errorf("invalid article ID %q, expected a number as shown by 'hudevto devto list'", arg)
+				}
+				ids = append(ids, id)
+			}
+
+			client, err := newClient(cmd)
+			if err != nil {
+				return err
+			}
 			rootDir, err := getRootDir(cmd)
 			if err != nil {
 				return err
 			}
-			return PushArticlesFromHugoToDevto(rootDir, pathToArticle, false, true, true, apiKey)
+			loadOpts, err := getLoadOptions(cmd)
+			if err != nil {
+				return err
+			}
+			sites, err := sync.LoadSites(rootDir, loadOpts)
+			if err != nil {
+				return err
+			}
+
+			importer := sync.Importer{RootDir: rootDir, Sites: sites, Client: client, Section: section}
+			failed := 0
+			err = importer.Import(cmd.Context(), ids, func(res sync.ImportResult) {
+				if res.Err != nil {
+					failed++
+					logutil.Errorf("devtoId %d: %s", res.ID, res.Err)
+					return
+				}
+				logutil.Infof("%s: imported %s (devtoId: %d, %d image(s) downloaded)",
+					logutil.Gray(res.Path),
+					res.Title,
+					res.ID,
+					len(res.Images),
+				)
+			})
+			if err != nil {
+				return err
+			}
+			if failed > 0 {
+				return &exitCodeErr{code: exitCodeError, msg: fmt.Sprintf("%d article(s) could not be imported", failed)}
+			}
+			return nil
 		},
 	}
+	cmd.Flags().StringVar(&section, "section", sync.DefaultImportSection, "The directory within the content directory where the posts are written.")
 	return cmd
 }
 
@@ -205,13 +401,18 @@ func devtoCmd() *cobra.Command {
 			if args[0] != "list" {
 				return fmt.Errorf("usage: hudevto devto list")
 			}
-			apiKey, err := getApiKey(cmd)
+			out, err := newRecordWriter(cmd)
+			if err != nil {
+				return err
+			}
+			client, err := newClient(cmd)
 			if err != nil {
 				return err
 			}
-			return PrintDevtoArticles(apiKey)
+			return PrintDevtoArticles(cmd.Context(), client, out)
 		},
 	}
+	addOutputFlag(cmd)
 	return cmd
 }
 
@@ -224,6 +425,18 @@ func getRootDir(cmd *cobra.Command) (string, error) {
 	return rootDir, nil
 }
 
+func getLoadOptions(cmd *cobra.Command) (sync.LoadOptions, error) {
+	config, err := cmd.Flags().GetString("config")
+	if err != nil {
+		return sync.LoadOptions{}, fmt.Errorf("--config: %w", err)
+	}
+	environment, err := cmd.Flags().GetString("environment")
+	if err != nil {
+		return sync.LoadOptions{}, fmt.Errorf("--environment: %w", err)
+	}
+	return sync.LoadOptions{Config: config, Environment: environment}, nil
+}
+
 func getApiKey(cmd *cobra.Command) (string, error) {
 	apiKey := os.Getenv("DEVTO_APIKEY")
 
@@ -240,401 +453,432 @@ func getApiKey(cmd *cobra.Command) (string, error) {
 	return apiKey, nil
 }
 
-// Updates all articles if pathToArticle is left empty. The pathToArticle must
-// be a markdown file, i.e., *.md. The rootDir cannot be left empty; if you want
-// to use the current working directory, use ".".
-func PushArticlesFromHugoToDevto(rootDirOrDot, relPathToArticle string, showMarkdown, showDiff, dryRun bool, apiKey string) error {
-	if rootDirOrDot == "" {
-		panic("programmer mistake: PushArticlesFromHugoToDevto: rootDirOrEmpty cannot be empty")
+// newClient returns a client for the Forem instance given with --devto-url,
+// which defaults to DEV.
+func newClient(cmd *cobra.Command) (*sync.Client, error) {
+	apiKey, err := getApiKey(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("while getting API key: %w", err)
 	}
-	rootDir := filepath.Clean(rootDirOrDot)
-	if rootDir == "." {
-		var err error
-		rootDir, err = os.Getwd()
-		if err != nil {
-			return fmt.Errorf("while getting current working directory: %w", err)
-		}
+	baseURL, err := cmd.Flags().GetString("devto-url")
+	if err != nil {
+		return nil, fmt.Errorf("--devto-url: %w", err)
 	}
-	logutil.Debugf("using rootDir='%s', rootDirOrEmpty='%s'", logutil.Gray(rootDir), logutil.Gray(rootDirOrDot))
-
-	fs := hugofs.NewBasePathFs(hugofs.Os, rootDir)
-	configs, err := allconfig.LoadConfig(allconfig.ConfigSourceDescriptor{
-		Fs:       fs,
-		Filename: "config.yaml",
-	})
+	retryBudget, err := cmd.Flags().GetDuration("retry-budget")
 	if err != nil {
-		return fmt.Errorf("while loading config: %w", err)
+		return nil, fmt.Errorf("--retry-budget: %w", err)
 	}
-
-	configProvider := config.New()
-	configProvider.Set("workingDir", rootDir)
-	configProvider.Set("publishDir", "unused")
-	configProvider.Set("themesDir", filepath.Join(rootDir, "themes"))
-
-	sites, err := hugolib.NewHugoSites(deps.DepsCfg{
-		Fs:      hugofs.NewFromSourceAndDestination(fs, fs, configProvider),
-		Configs: configs,
+	return sync.NewClient(baseURL, apiKey, sync.ClientOptions{
+		Debug:       logutil.EnableDebug,
+		RetryBudget: retryBudget,
 	})
+}
+
+// newPlanner loads the Hugo project found in --root and returns a planner. The
+// planner is nil when the Hugo project has no page.
+func newPlanner(cmd *cobra.Command) (*sync.Planner, error) {
+	client, err := newClient(cmd)
 	if err != nil {
-		return fmt.Errorf("while creating sites: %w", err)
+		return nil, err
+	}
+	rootDir, err := getRootDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	loadOpts, err := getLoadOptions(cmd)
+	if err != nil {
+		return nil, err
 	}
 
-	err = sites.Build(hugolib.BuildCfg{SkipRender: true})
+	sites, err := sync.LoadSites(rootDir, loadOpts)
 	if err != nil {
-		return fmt.Errorf("while processing content: %w", err)
+		return nil, err
 	}
 
 	if len(sites.Pages()) == 0 {
 		logutil.Errorf("no page found")
-		return nil
+		return nil, nil
 	}
 
-	httpClient := http.DefaultClient
-	httpClient.Transport = curlDebug(http.DefaultTransport, logutil.EnableDebug, apiKey)
-	client, err := devto.NewClient(context.Background(), &devto.Config{
-		APIKey: apiKey,
-	}, httpClient, "https://dev.to")
+	concurrency, err := cmd.Flags().GetInt("concurrency")
 	if err != nil {
-		return fmt.Errorf("devto client: %w", err)
+		return nil, fmt.Errorf("--concurrency: %w", err)
 	}
 
-	articles, err := listAllMyArticles(client)
+	seriesField, err := cmd.Flags().GetString("series-field")
 	if err != nil {
-		return fmt.Errorf("listing all the user's articles: %w", err)
+		return nil, fmt.Errorf("--series-field: %w", err)
 	}
 
-	articlesIdMap := make(map[int]*devto.ListedArticle)
-	articlesTitleMap := make(map[string]*devto.ListedArticle)
-	for i := range articles {
-		art := &articles[i]
-		articlesIdMap[int(art.ID)] = art
-		articlesTitleMap[art.Title] = art
-	}
+	return &sync.Planner{
+		RootDir:     rootDir,
+		Sites:       sites,
+		Client:      client,
+		Concurrency: concurrency,
+		SeriesField: seriesField,
+	}, nil
+}
 
-	pages := sites.Pages()
-	if relPathToArticle != "" {
-		p := sites.GetContentPage("/" + relPathToArticle)
-		if p == nil {
-			return fmt.Errorf("not found: %s", path.Join(rootDirOrDot, logutil.Gray(relPathToArticle)))
-		}
+// loadPlan loads the Hugo project and the user's DEV articles and plans all
+// the posts if relPathToArticle is left empty. The plan is empty when the Hugo
+// project has no page. The returned client is the one to be used for pushing.
+// Set forPush when the plan is about to be pushed, see sync.Planner.Push.
+func loadPlan(cmd *cobra.Command, relPathToArticle string, create, forPush bool) (*sync.Plan, *sync.Client, error) {
+	planner, err := newPlanner(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if planner == nil {
+		return &sync.Plan{}, nil, nil
+	}
+	planner.Create = create
+	planner.Push = forPush
 
-		pages = []page.Page{p}
+	// Only status has --check-images.
+	if flag := cmd.Flags().Lookup("check-images"); flag != nil {
+		planner.CheckImages = flag.Value.String()
 	}
 
-	for _, page := range pages {
-		if page.Kind() != "page" {
-			continue
-		}
+	plan, err := planner.Plan(cmd.Context(), relPathToArticle)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plan, planner.Client, nil
+}
 
-		// The pathToMD might either be:
-		//  - article.md             -> a markdown file
-		//  - article/index.md       -> a folder containing an index.md file
-		// Let's find which one it is.
-		pathToMD, err := pagePathToFilePath(rootDirOrDot, page.Path())
-		if err != nil {
-			logutil.Errorf("while getting path to MD for %s: %v",
-				logutil.Gray(page.Path()),
-				err,
+// Prints the posts that are either in error or skipped. Returns false when the
+// post has yet to be printed, i.e., when it needs to be pushed.
+func printNonPushed(post *sync.PostPlan) bool {
+	switch post.Action {
+	case sync.ActionError:
+		logutil.Errorf("%s: %s", logutil.Gray(post.Path), post.Err)
+	case sync.ActionSkip:
+		switch post.Reason {
+		case sync.ReasonNoChange:
+			logutil.Infof("%s: no change, skipping",
+				logutil.Gray(post.Path),
+			)
+		case sync.ReasonDevtoSkip:
+			logutil.Debugf("%s: field devtoSkip is true, skipping this post.",
+				logutil.Gray(post.Path),
 			)
-			continue
 		}
+	default:
+		return false
+	}
+	return true
+}
 
-		draft := true
-		draftRaw, err := page.Param("draft")
-		if err == nil {
-			draft = draftRaw.(bool)
-		}
-		if draft {
-			continue
-		}
+// Warns about the keywords that won't be sent as DEV tags so that the tags can
+// be fixed before pushing.
+func printDroppedTags(post *sync.PostPlan) {
+	if len(post.DroppedTags) == 0 {
+		return
+	}
+	logutil.Warnf("%s: these keywords won't be sent as DEV tags: %s (DEV only accepts up to %d alphanumeric tags, the tags sent are: %s)",
+		logutil.Gray(post.Path),
+		logutil.Red(strings.Join(post.DroppedTags, ", ")),
+		sync.MaxTags,
+		strings.Join(post.Article.Tags, ", "),
+	)
+}
 
-		devtoSkip := false
-		devtoSkipRaw, err := page.Param("devtoSkip")
-		if devtoSkipRaw != nil && err == nil {
-			var ok bool
-			devtoSkip, ok = devtoSkipRaw.(bool)
-			if !ok {
-				logutil.Errorf("%s: field devtoSkip is expected to be a boolean, got '%T'",
-					logutil.Gray(pathToMD),
-					devtoSkipRaw,
-				)
-				continue
+// Prints the warnings and errors that came up while planning or pushing the
+// post, see sync.Diagnostic.
+func printDiagnostics(post *sync.PostPlan) {
+	for _, d := range post.Diagnostics {
+		d.Print(post.Path)
+	}
+}
+
+// Reports the images that DEV won't be able to show, see --check-images.
+func printMissingImages(post *sync.PostPlan) {
+	for _, img := range post.MissingImages {
+		logutil.Errorf("%s:%d: image %s not found at %s: %s",
+			logutil.Gray(post.Path),
+			img.Line,
+			logutil.Red(img.Ref),
+			img.URL,
+			img.Reason,
+		)
+	}
+}
+
+// With groupBySeries, the posts are grouped by series in the order in which
+// each series first appears, and the posts that aren't part of a series come
+// last.
+func printStatus(plan *sync.Plan, out *recordWriter, groupBySeries bool) error {
+	posts := make([]*sync.PostPlan, len(plan.Posts))
+	for i := range plan.Posts {
+		posts[i] = &plan.Posts[i]
+	}
+	if groupBySeries {
+		posts = groupPostsBySeries(posts)
+	}
+
+	for i, post := range posts {
+		if groupBySeries && out.text() && (i == 0 || posts[i-1].Series != post.Series) {
+			printSeriesHeader(posts[i:])
+		}
+		if !out.text() {
+			if err := out.write(newPostRecord(post)); err != nil {
+				return err
 			}
+			continue
 		}
-		if devtoSkip {
-			logutil.Debugf("%s: field devtoSkip is true, skipping this post.",
-				logutil.Gray(pathToMD),
-			)
+		printDiagnostics(post)
+		printDroppedTags(post)
+		printMissingImages(post)
+		if printNonPushed(post) {
 			continue
 		}
 
-		devtoPublished := false
-		devtoPublishedRaw, err := page.Param("devtoPublished")
-		if devtoPublishedRaw == nil {
-			logutil.Errorf("%s: missing devtoPublished field",
-				logutil.Gray(pathToMD),
+		if post.Action == sync.ActionCreate {
+			fmt.Printf("%s: %s will be created %s on DEV (devtoPublished: %t)\n",
+				logutil.Yel("info"),
+				logutil.Gray(post.Path),
+				publishedStr(post.Published),
+				post.Published,
 			)
 			continue
 		}
-		if err == nil {
-			var ok bool
-			devtoPublished, ok = devtoPublishedRaw.(bool)
-			if !ok {
-				logutil.Errorf("%s: field devtoPublished is expected to be a boolean, got '%T'",
-					logutil.Gray(pathToMD),
-					devtoPublishedRaw,
-				)
-				continue
-			}
+
+		fmt.Printf("%s: %s will be pushed %s to %s (devtoId: %d, devtoPublished: %t, changed: %s)\n",
+			logutil.Yel("info"),
+			logutil.Gray(post.Path),
+			publishedStr(post.Published),
+			logutil.Yel(sync.AddEditSegment(post.Remote.URL.String(), post.Published)),
+			post.Remote.ID,
+			post.Published,
+			strings.Join(post.Changes, ", "),
+		)
+	}
+	return out.flush()
+}
+
+// groupPostsBySeries sorts the posts by series while keeping the order of the
+// posts within a series.
+func groupPostsBySeries(posts []*sync.PostPlan) []*sync.PostPlan {
+	rank := make(map[string]int)
+	for _, post := range posts {
+		if _, ok := rank[post.Series]; !ok && post.Series != "" {
+			rank[post.Series] = len(rank)
 		}
+	}
+	rank[""] = len(rank)
 
-		devtoIdRaw, err := page.Param("devtoId")
-		if err != nil || devtoIdRaw == nil {
-			if art, ok := articlesTitleMap[page.Title()]; ok {
-				logutil.Errorf("%s missing devtoId field in front matter, might be %s: %s",
-					logutil.Gray(pathToMD),
-					logutil.Green(strconv.Itoa(int(art.ID))),
-					logutil.Yel(addEditSegment(art.URL.String(), devtoPublished)),
-				)
-			} else {
-				logutil.Errorf("%s missing devtoId field in front matter and title cannot be found on your devto account",
-					logutil.Gray(pathToMD),
-				)
+	grouped := slices.Clone(posts)
+	slices.SortStableFunc(grouped, func(a, b *sync.PostPlan) int {
+		return rank[a.Series] - rank[b.Series]
+	})
+	return grouped
+}
+
+// The posts start with the series to be printed.
+func printSeriesHeader(posts []*sync.PostPlan) {
+	count := 0
+	for count < len(posts) && posts[count].Series == posts[0].Series {
+		count++
+	}
+	name := "no series"
+	if posts[0].Series != "" {
+		name = "series " + posts[0].Series
+	}
+	fmt.Printf("%s (%d post(s)):\n", logutil.Bold(name), count)
+}
+
+func printDiff(plan *sync.Plan, out *recordWriter) error {
+	for i := range plan.Posts {
+		post := &plan.Posts[i]
+		if !out.text() {
+			rec := newPostRecord(post)
+			switch post.Action {
+			case sync.ActionCreate:
+				rec.Diff = stripColors(FormatDiff("", post.Markdown))
+			case sync.ActionPush:
+				rec.Diff = stripColors(FormatDiff(post.Remote.BodyMarkdown, post.Markdown))
+			}
+			if err := out.write(rec); err != nil {
+				return err
 			}
 			continue
 		}
-		devtoId, ok := devtoIdRaw.(int)
-		if !ok {
-			logutil.Errorf("%s: field devtoId is expected to be an integer, got '%T'",
-				logutil.Gray(pathToMD),
-				devtoIdRaw,
-			)
+		printDiagnostics(post)
+		if printNonPushed(post) {
 			continue
 		}
 
-		article, found := articlesIdMap[devtoId]
-		if !found {
-			if art, ok := articlesTitleMap[page.Title()]; ok {
-				logutil.Errorf("%s: devtoId %s is unknown but title matches devtoId %s: %s",
-					logutil.Gray(pathToMD),
-					logutil.Red(strconv.Itoa(devtoId)),
-					logutil.Green(strconv.Itoa(int(art.ID))),
-					logutil.Yel(addEditSegment(art.URL.String(), devtoPublished)),
-				)
-			} else {
-				logutil.Errorf("%s: devtoId %s is unknown and title cannot be found in your devto account",
-					logutil.Gray(pathToMD),
-					logutil.Red(strconv.Itoa(devtoId)),
-				)
-			}
+		if post.Action == sync.ActionCreate {
+			logutil.Infof("%s: not on DEV yet, will be created",
+				logutil.Gray(post.Path),
+			)
+			fmt.Println(FormatDiff("", post.Markdown))
 			continue
 		}
 
-		if article.Title != page.Title() {
-			logutil.Errorf(heredoc.Docf(`
-				there seems to be a title mismatch in %s.
-				%s dev.to title
-				%s hugo title
-				%s
-				%s
-				To fix the mismatch, go to: %s`,
-				pathToMD,
-				logutil.Cyan("---"),
-				logutil.Cyan("+++"),
-				logutil.Cyan("- ")+logutil.Red(article.Title),
-				logutil.Cyan("+ ")+logutil.Green(page.Title()),
-				logutil.Yel(addEditSegment(article.URL.String(), devtoPublished)),
-			))
+		logutil.Infof("%s: found differences",
+			logutil.Gray(post.Path),
+		)
+		fmt.Println(FormatDiff(post.Remote.BodyMarkdown, post.Markdown))
+	}
+	return out.flush()
+}
+
+// Only the first post of the plan is shown.
+func printPreview(plan *sync.Plan) {
+	for i := range plan.Posts {
+		post := &plan.Posts[i]
+		printDiagnostics(post)
+		if post.Markdown == "" {
+			printNonPushed(post)
 			continue
 		}
 
-		img := ""
-		var imgs []string
-		imgsRaw, err := page.Param("images")
-		_, isEmptyArray := imgsRaw.([]any)
-		if imgsRaw != nil && !isEmptyArray && err == nil {
-			var ok bool
-			imgs, ok = imgsRaw.([]string)
-			if !ok {
-				logutil.Errorf("%s: field images is expected to be an array of strings, got '%T'",
-					logutil.Gray(pathToMD),
-					imgsRaw,
-				)
-				continue
+		fmt.Print(post.Markdown)
+		return
+	}
+}
+
+// Returns an error with the exit code exitCodeError when a post couldn't be
+// pushed.
+func push(ctx context.Context, plan *sync.Plan, executor sync.Executor, out *recordWriter) error {
+	var writeErr error
+	var failed int
+	executor.Execute(ctx, plan, func(res sync.Result) {
+		if res.Err != nil {
+			failed++
+		}
+		if !out.text() {
+			if err := out.write(newResultRecord(res)); err != nil && writeErr == nil {
+				writeErr = err
 			}
+			return
 		}
-		if len(imgs) > 0 {
-			img = sites.AbsURL(imgs[0], false)
+		printDiagnostics(res.Post)
+		if printNonPushed(res.Post) {
+			return
 		}
-
-		content := heredoc.Docf(`
-			---
-			title: "%s"
-			description: "%s"
-			published: %t
-			tags: "%s"
-			date: %s
-			series: "%s"
-			canonical_url: "%s"
-			cover_image: "%s"
-			---
-			`,
-			page.Title(),
-			page.Description(),
-			devtoPublished,
-			strings.Join(page.Keywords(), ", "),
-			page.Date().UTC().Format("20060102T15:04Z"),
-			strings.Join([]string{}, ", "),
-			page.Permalink(),
-			img,
-		)
-
-		body := page.RawContent()
-		body = convertHugoToLiquid(body)
-		body = addPostURLInImages(body, page.Permalink())
-		body = addPostURLInHTMLImages(body, page.Permalink())
-
-		if len(sites.Sites) > 0 {
-			body = convertAnchorIDs(pathToMD, body, sites.Sites[0].SanitizeAnchorName)
-		} else {
-			logutil.Errorf("%s: no site found, cannot convert anchor IDs",
-				logutil.Gray(pathToMD),
-			)
+		if res.Err != nil {
+			logutil.Errorf("%s: %s", logutil.Gray(res.Post.Path), res.Err)
+			return
 		}
 
-		content += body
-
-		if showMarkdown {
-			fmt.Print(content)
-			return nil
+		verb := "pushed"
+		if res.Post.Action == sync.ActionCreate {
+			verb = "created"
 		}
+		fmt.Printf("%s: %s %s %s to %s (devtoId: %d, devtoPublished: %t)\n",
+			logutil.Green("success"),
+			logutil.Gray(res.Post.Path),
+			verb,
+			publishedStr(res.Post.Published),
+			logutil.Yel(sync.AddEditSegment(res.Article.URL.String(), res.Post.Published)),
+			res.Article.ID,
+			res.Post.Published,
+		)
+	})
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := out.flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return &exitCodeErr{code: exitCodeError, msg: fmt.Sprintf("%d post(s) could not be pushed", failed)}
+	}
+	return nil
+}
 
-		existing, ok := articlesIdMap[devtoId]
-		if !ok {
-			logutil.Errorf("%s: the article id %s was not fetched from either /articles/me/published or /articles/me/unpublished",
-				logutil.Gray(pathToMD),
-				logutil.Yel(strconv.Itoa(devtoId)),
+// Shows the proposed mapping, asks for confirmation unless yes is true, and
+// writes devtoId and devtoPublished to the front matter of the posts.
+func link(stdin io.Reader, links []sync.Link, yes bool) error {
+	var found []sync.Link
+	for _, link := range links {
+		if link.Article == nil {
+			logutil.Infof("%s: no DEV article found for title %q",
+				logutil.Gray(link.Path),
+				link.Title,
 			)
 			continue
 		}
 
-		if existing.BodyMarkdown == content {
-			logutil.Infof("%s: no change, skipping",
-				logutil.Gray(pathToMD),
-			)
-			continue
+		match := string(link.Match)
+		if link.Match == sync.MatchFuzzyTitle {
+			match = logutil.Red(match) + ", please double check"
 		}
+		fmt.Printf("%s: %s -> %s %s (%s, matched by %s)\n",
+			logutil.Yel("link"),
+			logutil.Gray(link.Path),
+			logutil.Green(strconv.Itoa(int(link.Article.ID))),
+			logutil.Yel(sync.AddEditSegment(link.Article.URL.String(), link.Article.Published)),
+			link.Article.Title,
+			match,
+		)
+		found = append(found, link)
+	}
+	if len(found) == 0 {
+		logutil.Infof("nothing to link")
+		return nil
+	}
 
-		if showDiff && existing.BodyMarkdown != content {
-			logutil.Infof("%s: found differences",
-				logutil.Gray(pathToMD),
-			)
-			fmt.Println(FormatDiff(existing.BodyMarkdown, content))
-			continue
+	if !yes {
+		fmt.Printf("Write devtoId and devtoPublished to the front matter of %d post(s)? [y/N] ", len(found))
+		answer, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("while reading the answer: %w", err)
 		}
-
-		if dryRun {
-			publishedStr := logutil.Red("unpublished")
-			if devtoPublished {
-				publishedStr = logutil.Green("published")
-			}
-			fmt.Printf("%s: %s will be pushed %s to %s (devtoId: %d, devtoPublished: %t)\n",
-				logutil.Yel("info"),
-				logutil.Gray(pathToMD),
-				publishedStr,
-				logutil.Yel(addEditSegment(article.URL.String(), devtoPublished)),
-				article.ID,
-				devtoPublished,
-			)
-			continue
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			logutil.Infof("aborted, no front matter was changed")
+			return nil
 		}
+	}
 
-	Update:
-		art, err := UpdateArticle(httpClient, devtoId, Article{BodyMarkdown: content})
-		switch {
-		case isTooManyRequests(err):
-			// As per https://docs.forem.com/api/#operation/updateArticle,
-			// there is a limit of 30 requests per 30 seconds.
-			time.Sleep(1 * time.Second)
-			goto Update
-		case err != nil:
-			logutil.Errorf("%s: updating devto id %s: %s",
-				logutil.Gray(pathToMD),
-				logutil.Yel(strconv.Itoa(devtoId)),
+	for _, link := range found {
+		if err := sync.WriteLink(link); err != nil {
+			logutil.Errorf("%s: while writing devtoId and devtoPublished: %s",
+				logutil.Gray(link.Path),
 				err,
 			)
 			continue
 		}
-
-		// After a successful update, add the devtoUrl to the front matter.
-		if err := addDevtoUrlToFrontMatter(pathToMD, art.URL.String()); err != nil {
-			logutil.Errorf("%s: failed to update front matter with devtoUrl: %s",
-				logutil.Gray(pathToMD),
-				err,
-			)
-		}
-
-		publishedStr := logutil.Red("unpublished")
-		if devtoPublished {
-			publishedStr = logutil.Green("published")
-		}
-		fmt.Printf("%s: %s pushed %s to %s (devtoId: %d, devtoPublished: %t)\n",
+		fmt.Printf("%s: %s is now mapped to devtoId %d\n",
 			logutil.Green("success"),
-			logutil.Gray(pathToMD),
-			publishedStr,
-			logutil.Yel(addEditSegment(art.URL.String(), devtoPublished)),
-			art.ID,
-			devtoPublished,
+			logutil.Gray(link.Path),
+			link.Article.ID,
 		)
 	}
 	return nil
 }
 
-// Returns all the user's unpublished articles and then the published
-// articles.
-func listAllMyArticles(client *devto.Client) ([]devto.ListedArticle, error) {
-	// The max. number of items per page is 1000, see:
-	// https://docs.forem.com/api/#tag/articles.
-	articlesUnpublished, err := client.Articles.ListMyUnpublishedArticles(context.Background(), &devto.MyArticlesOptions{PerPage: 1000})
-	if err != nil {
-		return nil, fmt.Errorf("fetching unpublished articles: %s", err)
-	}
-	articlesPublished, err := client.Articles.ListMyPublishedArticles(context.Background(), &devto.MyArticlesOptions{PerPage: 1000})
-	if err != nil {
-		return nil, fmt.Errorf("fetching published articles: %s", err)
+func publishedStr(published bool) string {
+	if published {
+		return logutil.Green("published")
 	}
-	return append(articlesUnpublished, articlesPublished...), nil
+	return logutil.Red("unpublished")
 }
 
-func PrintDevtoArticles(apiKey string) error {
-	httpClient := http.DefaultClient
-	httpClient.Transport = curlDebug(http.DefaultTransport, logutil.EnableDebug, apiKey)
-	client, err := devto.NewClient(context.Background(), &devto.Config{
-		APIKey: apiKey,
-	}, httpClient, "https://dev.to")
+func PrintDevtoArticles(ctx context.Context, client *sync.Client, out *recordWriter) error {
+	articles, err := client.ListAllMyArticles(ctx)
 	if err != nil {
-		return fmt.Errorf("devto client: %w", err)
+		return fmt.Errorf("listing user's articles on dev.to: %w", err)
 	}
 
-	articles, err := listAllMyArticles(client)
 	for _, article := range articles {
-
-		publishedStr := logutil.Red("unpublished")
-		if article.Published {
-			publishedStr = logutil.Green("published")
+		if !out.text() {
+			if err := out.write(newArticleRecord(article)); err != nil {
+				return err
+			}
+			continue
 		}
 		fmt.Printf("%s: %s at %s (%s)\n",
 			logutil.Gray(strconv.Itoa(int(article.ID))),
-			publishedStr,
-			logutil.Yel(addEditSegment(article.URL.String(), article.Published)),
+			publishedStr(article.Published),
+			logutil.Yel(sync.AddEditSegment(article.URL.String(), article.Published)),
 			article.Title,
 		)
 	}
-	if err != nil {
-		return fmt.Errorf("listing user's articles on dev.to: %w", err)
-	}
-
-	return nil
+	return out.flush()
 }
 
 func isNotFound(err error) bool {
@@ -645,28 +889,6 @@ func isNotFound(err error) bool {
 	return devtoErr.Status == 404
 }
 
-func curlDebug(rt http.RoundTripper, debug bool, apiKey string) http.RoundTripper {
-	return &transport{wrapped: rt, outputCurl: debug, apiKey: apiKey}
-}
-
-type transport struct {
-	wrapped    http.RoundTripper
-	outputCurl bool
-	apiKey     string
-}
-
-func (rt transport) RoundTrip(r *http.Request) (*http.Response, error) {
-	if rt.outputCurl {
-		logutil.Debugf("%s", gencurl.FromRequest(r))
-	}
-
-	r.Header.Set("Accept", "application/json")
-	r.Header.Set("Content-Type", "application/json")
-	r.Header.Set("Api-Key", rt.apiKey)
-
-	return rt.wrapped.RoundTrip(r)
-}
-
 func selectArticle(articles []devto.Article, articleID uint32) (devto.Article, error) {
 	for _, article := range articles {
 		if article.ID == articleID {
@@ -676,312 +898,6 @@ func selectArticle(articles []devto.Article, articleID uint32) (devto.Article, e
 	return devto.Article{}, fmt.Errorf("article id %d not found", articleID)
 }
 
-// Get the published article using its ID. Note that it does not work for
-// unpublished articles.
-// https://developers.forem.com/api#operation/getArticleById
-func GetArticle(client *http.Client, articleID int) (devto.Article, error) {
-	path := fmt.Sprintf("/api/articles/%d", articleID)
-	req, err := http.NewRequest("GET", "https://dev.to"+path, nil)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("creating HTTP request for GET %s: %w", path, err)
-	}
-
-	httpResp, err := client.Do(req)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("while doing %s %s: %w", req.Method, path, err)
-	}
-	defer httpResp.Body.Close()
-
-	bytes, err := ioutil.ReadAll(httpResp.Body)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("while reading HTTP response for %s: %w", path, err)
-	}
-
-	switch httpResp.StatusCode {
-	case 200:
-		// continue below
-	default:
-		err = parseDevtoError(httpResp.StatusCode, bytes)
-		return devto.Article{}, err
-	}
-
-	var art devto.Article
-	err = json.Unmarshal(bytes, &art)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("while parsing JSON from the HTTP response for %s %s: %w", req.Method, path, err)
-	}
-
-	return art, nil
-}
-
-// https://developers.forem.com/api#operation/updateArticle
-func UpdateArticle(client *http.Client, articleID int, article Article) (devto.Article, error) {
-	articleReq := ArticleReq{Article: article}
-	raw, err := json.Marshal(&articleReq)
-	if err != nil {
-		panic("unexpected: " + err.Error())
-	}
-	reader := bytes.NewReader(raw)
-
-	path := fmt.Sprintf("/api/articles/%d", articleID)
-	req, err := http.NewRequest("PUT", "https://dev.to"+path, reader)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("creating HTTP request for %s %s: %w", req.Method, path, err)
-	}
-
-	httpResp, err := client.Do(req)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("while doing %s %s: %w", req.Method, path, err)
-	}
-	defer httpResp.Body.Close()
-
-	bytes, err := ioutil.ReadAll(httpResp.Body)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("while reading HTTP response for %s: %w", path, err)
-	}
-
-	switch httpResp.StatusCode {
-	case 200:
-		// continue below
-	default:
-		err = parseDevtoError(httpResp.StatusCode, bytes)
-		return devto.Article{}, err
-	}
-
-	var art devto.Article
-	err = json.Unmarshal(bytes, &art)
-	if err != nil {
-		return devto.Article{}, fmt.Errorf("while parsing JSON from the HTTP response for %s %s: %w", req.Method, path, err)
-	}
-
-	return art, nil
-}
-
-type ArticleReq struct {
-	Article Article `json:"article"`
-}
-
-type Article struct {
-	BodyMarkdown string `json:"body_markdown"`
-}
-
-type DevtoError struct {
-	Err    string `json:"error"`
-	Status int    `json:"status"`
-}
-
-func (e DevtoError) Error() string { return e.Err }
-
-func parseDevtoError(status int, bytes []byte) error {
-	var errResp DevtoError
-	if err := json.Unmarshal(bytes, &errResp); err != nil {
-		return DevtoError{Status: status, Err: strings.TrimSpace(string(bytes))}
-	}
-
-	return errResp
-}
-
-func isTooManyRequests(err error) bool {
-	if err == nil {
-		return false
-	}
-	devtoErr, ok := err.(DevtoError)
-	if !ok {
-		return false
-	}
-	return devtoErr.Status == 429
-}
-
-// We want to have "/edit" at the end of URLs that are not yet published
-// since these cannot be accessed without "/edit".
-func addEditSegment(articleURL string, published bool) string {
-	if !published {
-		articleURL += "/edit"
-	}
-	return articleURL
-}
-
-// client.Articles.ListAllMyArticles was not actually listing all articles
-// and would only show the unpublished ones. Also, it would only show the
-// first 20.
-
-var hugoTag = regexp.MustCompile("{{< ([a-z]+) (.*) >}}")
-
-// Hugo tag:
-//
-//	{{< youtube 30a0WrfaS2A >}}
-//
-// Devto liquid:
-//
-//	{% youtube 30a0WrfaS2A %}
-//
-// Ref: https://docs.dev.to/frontend/liquid-tags
-func convertHugoToLiquid(in string) string {
-	return hugoTag.ReplaceAllString(in, "{% $1 $2 %}")
-}
-
-// I want to be able to add the base post URL to each image. For example,
-// imagine that the post is
-//
-//	https://maelvls.dev/you-should-write-comments/index.md
-//
-// then I need to replace the images, such as:
-//
-//	![My image](cover-you-should-write-comments.png)
-//
-// with:
-//
-//	![My image](/you-should-write-comments/cover-you-should-write-comments.png)
-//	            <------ basePostURL ------>
-//	           (basePostURL already includes the leading / and trailing /)
-//
-// Note: (?s) means multiline, (?U) means non-greedy.
-var mdImg = regexp.MustCompile(`(?sU)\!\[([^\]]*)\]\((\S*)\)`)
-
-func addPostURLInImages(in string, basePostURL string) string {
-	return mdImg.ReplaceAllString(in, "![$1]("+basePostURL+"$2)")
-}
-
-// Since you can also embed `<img>` tags in markdown, these are also converted. For example,
-//
-//	<img alt="Super example" src="dnat-google-vpc-how-comes-back.svg" width="80%"/>
-//
-// is converted to:
-//
-//	<img alt="Super example" src="/you-should-write-comments/dnat-google-vpc-how-comes-back.svg" width="80%"/>
-//
-// Only the following image extensions are converted: png, PNG, jpeg, JPG, jpg,
-// gif, GIF, svg, SVG.
-//
-// (?s) means multiline, (?U) means non-greedy.
-var htmlImg = regexp.MustCompile(`(?sU)src="([^"]*(png|PNG|jpeg|JPG|jpg|gif|GIF|svg|SVG))"`)
-
-func addPostURLInHTMLImages(in string, basePostURL string) string {
-	return htmlImg.ReplaceAllString(in, `src="`+basePostURL+`$1"`)
-}
-
-// The convertAnchorIDs function reads Markdown, finds any anchor-based link of
-// the form [foo](#foo) and converts the GitHub-style anchor IDs to Devto anchor
-// IDs. This is because GitHub-style anchor IDs, which is what Hugo produces,
-// are different from the ones produced by Devto. For example, take the
-// following Markdown:
-//
-//	[`go get -u` vs. `go.mod` (= *_Problem_*)](#go-get--u-vs-gomod--_problem_)
-//
-// becomes
-//
-//	[`go get -u` vs. `go.mod` (= *_Problem_*)](#-raw-go-get-u-endraw-vs-raw-gomod-endraw-problem)
-
-var linkWithOnlyAnchor = regexp.MustCompile(`\[([^\]]*)\]\(#([^\)]*)\)`)
-var code = regexp.MustCompile("`([^`]*)`")
-var whitespace = regexp.MustCompile(`\s+`)
-var nonAlphaNumExceptDashAndSpace = regexp.MustCompile(`[^-a-zA-Z0-9]`)
-var multipleDashes = regexp.MustCompile(`-{2,}`)
-
-// only ATX headings are supported (headings of the form "# Title")
-func convertAnchorIDs(pathToMD, in string, sanitizeAnchorName func(s string) string) string {
-	inBytes := []byte(in)
-	parsed := goldmark.DefaultParser().Parse(text.NewReader(inBytes))
-
-	anchorToHeading := make(map[string]string)
-	ast.Walk(parsed, func(node ast.Node, _ bool) (ast.WalkStatus, error) {
-		headingNode, ok := node.(*ast.Heading)
-		if ok {
-			if headingNode.Lines().Len() != 1 {
-				logutil.Errorf("unexpected heading: %s", headingNode.Text(inBytes))
-				return ast.WalkContinue, nil
-			}
-			seg := headingNode.Lines().At(0)
-			heading := string(seg.Value(inBytes))
-
-			anchorToHeading[sanitizeAnchorName(heading)] = heading
-		}
-		return ast.WalkContinue, nil
-	})
-
-	return linkWithOnlyAnchor.ReplaceAllStringFunc(in, func(s string) string {
-		matches := linkWithOnlyAnchor.FindStringSubmatch(s)
-		if len(matches) != 3 {
-			return s
-		}
-
-		// We ignore the "text" part, since we will use the headings that we
-		// found when parsing the Markdown document.
-		//
-		//  [`go get -u` vs. `go.mod` (= *_problem_*)](#go-get--u-vs-gomod--_problem_)
-		//   <-------------------------------------->  <-------------------------->
-		//                text is ignored                         anchor
-		anchor := matches[2]
-		heading, found := anchorToHeading[anchor]
-		if !found {
-			possibleAnchors := make([]string, 0, len(anchorToHeading))
-			for anchor := range anchorToHeading {
-				possibleAnchors = append(possibleAnchors, anchor)
-			}
-			matcher := closestmatch.New(possibleAnchors, []int{2})
-
-			logutil.Errorf("%s: anchor %q in link %s doesn't exist in the document. Did you mean %s?",
-				logutil.Gray(pathToMD),
-				anchor, s,
-				matcher.Closest(anchor),
-			)
-
-			return s
-		}
-
-		// Rule 1: `foo` is converted to `raw-foo-endraw-`.
-		heading = code.ReplaceAllString(heading, "-raw-$1-endraw-")
-
-		// Rule 2: whitespaces (spaces and tabs) are replaced with a dash (-).
-		heading = whitespace.ReplaceAllString(heading, "-")
-
-		// Rule 3: all other non-alphanumeric characters are removed.
-		heading = nonAlphaNumExceptDashAndSpace.ReplaceAllString(heading, "")
-
-		// Rule 4: two dashes or more are combined into a single dash.
-		heading = multipleDashes.ReplaceAllString(heading, "-")
-
-		// Rule 5: the anchor ID is lowercase.
-		heading = strings.ToLower(heading)
-
-		// Replace the anchor ID.
-		return strings.Replace(s, "#"+matches[2], "#"+heading, 1)
-	})
-}
-
-// addDevtoUrlToFrontMatter adds or updates the devtoUrl field in the front matter of a Markdown file
-func addDevtoUrlToFrontMatter(filePath string, url string) error {
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("reading file: %w", err)
-	}
-
-	contentStr := string(content)
-
-	// Check if there's YAML front matter (between --- markers).
-	frontMatterRegex := regexp.MustCompile(`(?s)^---\n(.*?)\n---`)
-	match := frontMatterRegex.FindStringSubmatch(contentStr)
-	if len(match) < 2 {
-		return fmt.Errorf("front matter not found in %s", filePath)
-	}
-
-	frontMatter := match[1]
-
-	// Check if devtoUrl already exists
-	devtoUrlRegex := regexp.MustCompile(`(?m)^devtoUrl:\s*.*$`)
-	if devtoUrlRegex.MatchString(frontMatter) {
-		// Replace existing devtoUrl
-		updatedFrontMatter := devtoUrlRegex.ReplaceAllString(frontMatter, fmt.Sprintf("devtoUrl: %s", url))
-		updatedContent := frontMatterRegex.ReplaceAllString(contentStr, fmt.Sprintf("---\n%s\n---", updatedFrontMatter))
-		return os.WriteFile(filePath, []byte(updatedContent), 0644)
-	}
-
-	// Add new devtoUrl field
-	updatedFrontMatter := fmt.Sprintf("%s\ndevtoUrl: %s", frontMatter, url)
-	updatedContent := frontMatterRegex.ReplaceAllString(contentStr, fmt.Sprintf("---\n%s\n---", updatedFrontMatter))
-	return os.WriteFile(filePath, []byte(updatedContent), 0644)
-}
-
 func errWorkDirTooShort(err error) bool {
 	if err == nil {
 		return false
@@ -992,45 +908,3 @@ func errWorkDirTooShort(err error) bool {
 
 	return false
 }
-
-// This is meant to turn the URL path returned by Hugo's page.Path() into a file
-// path to the Markdown file of the page. For example, if the page.Path() is
-//
-//	article
-//
-// then the file path might either be:
-//
-//	(1) A markdown file:                  content/article.md
-//	(2) A folder containing index.md:    content/article/index.md
-//
-// This func returns the file path out of the URL path. The rootDir cannot be
-// empty; to use the current dir, usr ".".
-func pagePathToFilePath(rootDir, hugoPath string) (string, error) {
-	// Case (1): the hugoPath is a file, so we return the file path.
-	pathIsMD := filepath.Join(rootDir, "content", hugoPath+".md")
-	_, err := os.Stat(pathIsMD)
-	if err == nil {
-		return pathIsMD, nil
-	}
-	if !os.IsNotExist(err) {
-		return "", fmt.Errorf("while checking if %s exists: %w", pathIsMD, err)
-	}
-
-	// At this point, we know that it's not (2), so it must be (1).
-	pathIsIndexMD := filepath.Join(rootDir, "content", hugoPath, "index.md")
-	_, err = os.Stat(pathIsIndexMD)
-	if err == nil {
-		return pathIsIndexMD, nil
-	}
-	if !os.IsNotExist(err) {
-		return "", fmt.Errorf("while checking if %s exists: %w", pathIsIndexMD, err)
-	}
-
-	return "", fmt.Errorf("wasn't able to find the source file for the URL path %s, tried:\n"+
-		"  - as a Markdown file (%s)\n"+
-		"  - as an index file (%s)",
-		hugoPath,
-		logutil.Gray(pathIsMD),
-		logutil.Gray(pathIsIndexMD),
-	)
-}
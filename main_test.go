@@ -1,149 +1,224 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"os"
-	"path"
-	"path/filepath"
-	"strconv"
+	"regexp"
 	"strings"
 	"testing"
 
-	"github.com/maelvls/undent"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/maelvls/hudevto/devtotest"
+	"github.com/maelvls/hudevto/sync"
 )
 
-func TestPagePathToFilePath(t *testing.T) {
-	t.Run("file exists", func(t *testing.T) {
-		root := t.TempDir()
-		withContentDir(t, root, "content/article.md")
+func Test_statusAndPush(t *testing.T) {
+	root, srv, hudevto := newTestHudevto(t)
 
-		p, err := pagePathToFilePath(root, "article")
+	out := hudevto("status", "content/posts/published.md")
+	assert.Equal(t, "info: "+root+"/content/posts/published.md will be pushed published to "+srv.URL+"/tester/published-post-rt (devtoId: 1001, devtoPublished: true, changed: tags, description, canonical_url, body)\n", out)
 
-		require.NoError(t, err)
-		require.Equal(t, filepath.Join(root, "content/article.md"), p)
-	})
+	out = hudevto("status", "content/posts/new.md")
+	assert.Equal(t, "error: "+root+"/content/posts/new.md: missing devtoId field in front matter and title cannot be found on your devto account\n", out)
 
-	t.Run("directory with index.md exists", func(t *testing.T) {
-		root := t.TempDir()
-		withContentDir(t, root, "content/my-article/index.md")
+	out = hudevto("status", "--create", "content/posts/new.md")
+	assert.Equal(t, "info: "+root+"/content/posts/new.md will be created unpublished on DEV (devtoPublished: false)\n", out)
 
-		p, err := pagePathToFilePath(root, "my-article")
+	newMD := root + "/content/posts/new.md"
+	content, err := os.ReadFile(newMD)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(newMD, []byte(strings.Replace(string(content), "keywords: [hugo]", "keywords: [hugo, go, blog, devto, web]", 1)), 0644))
+	out = hudevto("status", "--create", "content/posts/new.md")
+	assert.Equal(t, "warning: "+root+"/content/posts/new.md: these keywords won't be sent as DEV tags: web (DEV only accepts up to 4 alphanumeric tags, the tags sent are: hugo, go, blog, devto)\n"+
+		"info: "+root+"/content/posts/new.md will be created unpublished on DEV (devtoPublished: false)\n", out)
 
-		require.NoError(t, err)
-		require.Equal(t, filepath.Join(root, "content/my-article/index.md"), p)
-	})
+	out = hudevto("push", "--create", "content/posts/new.md")
+	assert.Equal(t, "success: "+root+"/content/posts/new.md created unpublished to "+srv.URL+"/tester/brand-new-post-rv-temp-slug-1003/edit (devtoId: 1003, devtoPublished: false)\n", out)
+
+	out = hudevto("status", "content/posts/new.md")
+	assert.Equal(t, "warning: "+root+"/content/posts/new.md: these keywords won't be sent as DEV tags: web (DEV only accepts up to 4 alphanumeric tags, the tags sent are: hugo, go, blog, devto)\n"+
+		"info: "+root+"/content/posts/new.md: no change, skipping\n", out)
+}
 
-	t.Run("neither exists", func(t *testing.T) {
-		root := t.TempDir()
+func Test_output(t *testing.T) {
+	root, srv, hudevto := newTestHudevto(t)
+
+	t.Run("status as json", func(t *testing.T) {
+		out := hudevto("status", "content/posts/new.md", "-o", "json")
+		assert.Equal(t, `[
+  {
+    "path": "`+root+`/content/posts/new.md",
+    "action": "error",
+    "reason": "missing-devto-id",
+    "published": false,
+    "error": "missing devtoId field in front matter and title cannot be found on your devto account"
+  }
+]
+`, out)
+	})
 
-		p, err := pagePathToFilePath(root, "notfound")
-		require.Error(t, err)
-		assert.Empty(t, p)
-		assert.Equal(t, undent.Undent(`
-			wasn't able to find the source file for the URL path notfound, tried:
-			  - as a Markdown file (<root>/content/notfound.md)
-			  - as an index file (<root>/content/notfound/index.md)`),
-			rmAnsicodes(strings.ReplaceAll(err.Error(), root, "<root>")))
+	t.Run("status as yaml", func(t *testing.T) {
+		out := hudevto("status", "content/posts/published.md", "-o", "yaml")
+		assert.Equal(t, `- path: `+root+`/content/posts/published.md
+  devtoId: 1001
+  action: push
+  reason: changed
+  url: `+srv.URL+`/tester/published-post-rt
+  published: true
+  changes:
+    - tags
+    - description
+    - canonical_url
+    - body
+`, out)
+	})
 
+	t.Run("diff as json", func(t *testing.T) {
+		out := hudevto("diff", "content/posts/published.md", "-o", "json")
+		var records []postRecord
+		require.NoError(t, json.Unmarshal([]byte(out), &records))
+		require.Len(t, records, 1)
+		assert.Equal(t, "push", records[0].Action)
+		assert.Contains(t, records[0].Diff, "- outdated")
+		assert.Contains(t, records[0].Diff, "+ title: \"Published post\"\n")
 	})
 
-	t.Run("permission error", func(t *testing.T) {
-		root := t.TempDir()
+	t.Run("push as ndjson", func(t *testing.T) {
+		out := hudevto("push", "content/posts/published.md", "-o", "ndjson")
+		assert.Equal(t, `{"path":"`+root+`/content/posts/published.md","devtoId":1001,"action":"push","reason":"changed","url":"`+srv.URL+`/tester/published-post-rt","published":true,"changes":["tags","description","canonical_url","body"]}`+"\n", out)
+	})
 
-		badDir := filepath.Join(root, "content")
-		err := os.MkdirAll(badDir, 0000)
-		require.NoError(t, err)
+	t.Run("devto list as ndjson", func(t *testing.T) {
+		out := hudevto("devto", "list", "-o", "ndjson")
+		assert.Equal(t, ""+
+			`{"id":1002,"title":"Post with an image","url":"`+srv.URL+`/tester/post-with-an-image-ru","published":false}`+"\n"+
+			`{"id":1001,"title":"Published post","url":"`+srv.URL+`/tester/published-post-rt","published":true}`+"\n",
+			out)
+	})
 
-		_, err = pagePathToFilePath(root, "bad-article")
-		require.Error(t, err)
-		assert.Contains(t, err.Error(), "content/bad-article.md: permission denied")
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := run(t, "status", "-o", "xml")
+		assert.EqualError(t, err, `--output: unknown format "xml", expected one of text, json, yaml or ndjson`)
 	})
 }
 
-// path can be:
-// - content/article.md
-// - content/other/index.md
-func withContentDir(t *testing.T, tmpDir, mdPath string) {
-	dir := filepath.Join(tmpDir, path.Dir(mdPath))
-	err := os.MkdirAll(dir, 0755)
-	require.NoError(t, err, "failed to create content directory")
-
-	filePath := filepath.Join(dir, path.Base(mdPath))
-	err = os.WriteFile(filePath, []byte("test content"), 0644)
-	require.NoError(t, err, "failed to create content file")
-}
+func Test_exitCode(t *testing.T) {
+	root, srv, _ := newTestHudevto(t)
+	exitCode := func(args ...string) int {
+		t.Helper()
+		_, err := runHudevto(t, root, srv, args...)
+		var exitErr *exitCodeErr
+		switch {
+		case errors.As(err, &exitErr):
+			return exitErr.code
+		case err != nil:
+			t.Fatalf("unexpected error: %s", err)
+		}
+		return 0
+	}
+
+	// Missing devtoId.
+	assert.Equal(t, exitCodeError, exitCode("status"))
+	assert.Equal(t, exitCodeError, exitCode("diff"))
+	assert.Equal(t, exitCodeError, exitCode("status", "content/posts/new.md"))
+
+	assert.Equal(t, exitCodePending, exitCode("status", "content/posts/published.md"))
+	assert.Equal(t, exitCodePending, exitCode("diff", "content/posts/published.md"))
+	assert.Equal(t, exitCodePending, exitCode("status", "--create", "content/posts/new.md"))
+
+	assert.Equal(t, 0, exitCode("push", "content/posts/published.md"))
+	assert.Equal(t, 0, exitCode("status", "content/posts/published.md"))
+	assert.Equal(t, 0, exitCode("status", "--check-images", "content/posts/published.md"))
+	assert.Equal(t, 0, exitCode("diff", "content/posts/published.md"))
+	assert.Equal(t, 0, exitCode("status", "content/posts/skipped.md"))
+
+	// Already mapped to content/posts/published.md.
+	assert.Equal(t, exitCodeError, exitCode("import", "1001"))
 
-func rmAnsicodes(s string) string {
-	// Remove ANSI escape codes from the string.
-	// This is a simple implementation that removes all escape sequences.
-	return strings.NewReplacer(
-		"\x1b[0;90m", "",
-		"\x1b[0m", "",
-	).Replace(s)
+	// DEV refuses the new post since its canonical URL is already taken.
+	srv.AddArticle(devtotest.Article{ID: 2001, Title: "Someone else's post", CanonicalURL: "https://blog.example.com/posts/new/"})
+	assert.Equal(t, exitCodeError, exitCode("push", "--create", "content/posts/new.md"))
+	assert.Equal(t, exitCodeError, exitCode("push", "--create", "content/posts/new.md", "-o", "json"))
 }
 
-func Test_convertAnchorIDs(t *testing.T) {
-	tests := []struct {
-		// I initially wanted to use Hugo's SanitizeAnchorName, but it would
-		// require a complicated setup. Instead, I pretend that I'm Hugo and I
-		// do the conversion myself.
-		headingToAnchor map[string]string
-		given, expect   string
-	}{
-		{
-			headingToAnchor: map[string]string{"`go get -u` vs. `go.mod` (= *_problem_*)": "go-get--u-vs-gomod--_problem_"},
-			given: "# `go get -u` vs. `go.mod` (= *_problem_*)\n" +
-				"[`go get -u` vs. `go.mod` (= *_problem_*)](#go-get--u-vs-gomod--_problem_)\n",
-			expect: "# `go get -u` vs. `go.mod` (= *_problem_*)\n" +
-				"[`go get -u` vs. `go.mod` (= *_problem_*)](#-raw-go-get-u-endraw-vs-raw-gomod-endraw-problem)\n",
-		},
-		{
-			// The closing endraw is always followed by a dash.
-			headingToAnchor: map[string]string{"`foo`": "foo"},
-			given: "# `foo`\n" +
-				"[`foo`](#foo)\n",
-			expect: "# `foo`\n" +
-				"[`foo`](#-raw-foo-endraw-)\n",
-		},
-		{
-			// Upper case letters are converted to lower case.
-			headingToAnchor: map[string]string{"Title Is CAPITAL": "title-is-capital"},
-			given: "### Title Is CAPITAL\n" +
-				"[Title Is CAPITAL](#title-is-capital)\n",
-			expect: "### Title Is CAPITAL\n" +
-				"[Title Is CAPITAL](#title-is-capital)\n",
-		},
-		{
-			// It should not affect the anchor links that were manually created.
-			headingToAnchor: map[string]string{"Docker proxy vs. Local registry": "docker-proxy-vs-local-registry"},
-			given: "## Docker proxy vs. Local registry\n" +
-				"[see this section](#docker-proxy-vs-local-registry)\n",
-			expect: "## Docker proxy vs. Local registry\n" +
-				"[see this section](#docker-proxy-vs-local-registry)\n",
-		},
+func Test_groupPostsBySeries(t *testing.T) {
+	posts := []*sync.PostPlan{
+		{Path: "a", Series: "Go"},
+		{Path: "b"},
+		{Path: "c", Series: "Hugo"},
+		{Path: "d", Series: "Go"},
+		{Path: "e"},
 	}
-	for i, tt := range tests {
-		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			assert.Equal(t, tt.expect, convertAnchorIDs("path/to/file.md", tt.given, func(s string) string {
-				return tt.headingToAnchor[s]
-			}))
-		})
+	var got []string
+	for _, post := range groupPostsBySeries(posts) {
+		got = append(got, post.Path)
 	}
+	assert.Equal(t, []string{"a", "d", "c", "b", "e"}, got)
 }
 
-func Test_addPostURLInHTMLImages(t *testing.T) {
-	tests := []struct {
-		given, expect string
-	}{
-		{
-			`<img alt="Super example" src="dnat-google-vpc-how-comes-back.svg" width="80%"/>`,
-			`<img alt="Super example" src="/you-should-write-comments/dnat-google-vpc-how-comes-back.svg" width="80%"/>`,
-		},
-	}
-	for i, tt := range tests {
-		t.Run(strconv.Itoa(i), func(t *testing.T) {
-			assert.Equal(t, tt.expect, addPostURLInHTMLImages(tt.given, "/you-should-write-comments/"))
-		})
+// newTestHudevto copies the test site to a temporary directory and starts a
+// fake DEV on which the published post and the post with an image exist but
+// are outdated. The returned func runs hudevto against them and returns its
+// output; the exit codes are ignored.
+func newTestHudevto(t *testing.T) (root string, srv *devtotest.Server, hudevto func(args ...string) string) {
+	t.Helper()
+	srv = devtotest.NewServer(t)
+	srv.AddArticle(devtotest.Article{ID: 1001, Title: "Published post", Published: true, BodyMarkdown: "outdated"})
+	srv.AddArticle(devtotest.Article{ID: 1002, Title: "Post with an image", BodyMarkdown: "outdated"})
+	root = t.TempDir()
+	require.NoError(t, os.CopyFS(root, os.DirFS("testdata/site")))
+
+	hudevto = func(args ...string) string {
+		t.Helper()
+		out, err := runHudevto(t, root, srv, args...)
+		var exitErr *exitCodeErr
+		if !errors.As(err, &exitErr) {
+			require.NoError(t, err)
+		}
+		return out
 	}
+	return root, srv, hudevto
+}
+
+// runHudevto runs hudevto against the site in root and the fake DEV.
+func runHudevto(t *testing.T, root string, srv *devtotest.Server, args ...string) (string, error) {
+	t.Helper()
+	return run(t, append([]string{"--root", root, "--devto-url", srv.URL, "--apikey", srv.APIKey}, args...)...)
+}
+
+var ansiCodes = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// run runs hudevto with the given arguments and returns what was printed to
+// stdout and stderr, without the colors.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	defer func() { os.Stdout, os.Stderr = stdout, stderr }()
+
+	outCh := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		outCh <- string(out)
+	}()
+
+	// Like fang.Execute does.
+	cmd := mainCmd()
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	cmd.SetArgs(args)
+	cmd.SetOut(w)
+	cmd.SetErr(w)
+	err = cmd.ExecuteContext(context.Background())
+	w.Close()
+
+	return ansiCodes.ReplaceAllString(<-outCh, ""), err
 }
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/maelvls/hudevto/sync"
+)
+
+// The formats accepted by --output. With "text", the output is meant for
+// humans and is colored. The other formats print one record per post (or per
+// DEV article for 'devto list') and don't print anything else to stdout.
+const (
+	outputText   = "text"
+	outputJSON   = "json"
+	outputYAML   = "yaml"
+	outputNDJSON = "ndjson"
+)
+
+// postRecord is what status, diff and push print for each post when --output
+// isn't "text".
+type postRecord struct {
+	Path          string        `json:"path" yaml:"path"`
+	DevtoID       int           `json:"devtoId,omitempty" yaml:"devtoId,omitempty"`
+	Action        string        `json:"action" yaml:"action"`
+	Reason        string        `json:"reason,omitempty" yaml:"reason,omitempty"`
+	URL           string        `json:"url,omitempty" yaml:"url,omitempty"`
+	Published     bool          `json:"published" yaml:"published"`
+	Series        string        `json:"series,omitempty" yaml:"series,omitempty"`
+	Changes       []string      `json:"changes,omitempty" yaml:"changes,omitempty"`
+	DroppedTags   []string      `json:"droppedTags,omitempty" yaml:"droppedTags,omitempty"`
+	MissingImages []imageRecord `json:"missingImages,omitempty" yaml:"missingImages,omitempty"`
+	Diagnostics   []diagRecord  `json:"diagnostics,omitempty" yaml:"diagnostics,omitempty"`
+	Error         string        `json:"error,omitempty" yaml:"error,omitempty"`
+	Diff          string        `json:"diff,omitempty" yaml:"diff,omitempty"`
+}
+
+// imageRecord is an image that can't be found, see 'status --check-images'.
+type imageRecord struct {
+	Line   int    `json:"line" yaml:"line"`
+	Ref    string `json:"ref" yaml:"ref"`
+	URL    string `json:"url" yaml:"url"`
+	Reason string `json:"reason" yaml:"reason"`
+}
+
+// diagRecord is a warning or an error about a post, see sync.Diagnostic.
+type diagRecord struct {
+	Level   string `json:"level" yaml:"level"`
+	Message string `json:"message" yaml:"message"`
+}
+
+// articleRecord is what 'devto list' prints for each DEV article when
+// --output isn't "text".
+type articleRecord struct {
+	ID        int    `json:"id" yaml:"id"`
+	Title     string `json:"title" yaml:"title"`
+	URL       string `json:"url" yaml:"url"`
+	Published bool   `json:"published" yaml:"published"`
+}
+
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", outputText, "Output format, one of text, json, yaml or ndjson. With ndjson, one JSON record is printed per line as soon as it is known.")
+}
+
+// recordWriter prints the records in the format given with --output. The
+// json and yaml formats need all the records to be known, so they are only
+// printed by flush; ndjson records are printed right away.
+type recordWriter struct {
+	format  string
+	w       io.Writer
+	records []any
+}
+
+func newRecordWriter(cmd *cobra.Command) (*recordWriter, error) {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		return nil, fmt.Errorf("--output: %w", err)
+	}
+	switch format {
+	case outputText, outputJSON, outputYAML, outputNDJSON:
+	default:
+		return nil, fmt.Errorf("--output: unknown format %q, expected one of text, json, yaml or ndjson", format)
+	}
+	return &recordWriter{format: format, w: cmd.OutOrStdout(), records: []any{}}, nil
+}
+
+// text tells whether the output is meant for humans.
+func (rw *recordWriter) text() bool {
+	return rw.format == outputText
+}
+
+func (rw *recordWriter) write(record any) error {
+	if rw.format != outputNDJSON {
+		rw.records = append(rw.records, record)
+		return nil
+	}
+	return json.NewEncoder(rw.w).Encode(record)
+}
+
+func (rw *recordWriter) flush() error {
+	switch rw.format {
+	case outputJSON:
+		enc := json.NewEncoder(rw.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rw.records)
+	case outputYAML:
+		enc := yaml.NewEncoder(rw.w)
+		enc.SetIndent(2)
+		if err := enc.Encode(rw.records); err != nil {
+			return err
+		}
+		return enc.Close()
+	}
+	return nil
+}
+
+func newPostRecord(post *sync.PostPlan) postRecord {
+	rec := postRecord{
+		Path:        post.Path,
+		DevtoID:     post.DevtoID,
+		Action:      string(post.Action),
+		Reason:      string(post.Reason),
+		Published:   post.Published,
+		Series:      post.Series,
+		Changes:     post.Changes,
+		DroppedTags: post.DroppedTags,
+	}
+	for _, img := range post.MissingImages {
+		rec.MissingImages = append(rec.MissingImages, imageRecord{Line: img.Line, Ref: img.Ref, URL: img.URL, Reason: img.Reason})
+	}
+	for _, d := range post.Diagnostics {
+		rec.Diagnostics = append(rec.Diagnostics, diagRecord{Level: string(d.Level), Message: stripColors(d.Message)})
+	}
+	if post.Remote != nil && post.Remote.URL != nil {
+		rec.URL = post.Remote.URL.String()
+	}
+	if post.Err != nil {
+		rec.Error = stripColors(post.Err.Error())
+	}
+	return rec
+}
+
+// newResultRecord returns the record of a post once the push is done. A push
+// that failed is reported with the action "error".
+func newResultRecord(res sync.Result) postRecord {
+	rec := newPostRecord(res.Post)
+	switch {
+	case res.Err != nil:
+		rec.Action = string(sync.ActionError)
+		rec.Reason = string(sync.ReasonPushFailed)
+		rec.Error = stripColors(res.Err.Error())
+	case res.Article.URL != nil:
+		rec.DevtoID = int(res.Article.ID)
+		rec.URL = res.Article.URL.String()
+	}
+	return rec
+}
+
+func newArticleRecord(article devto.ListedArticle) articleRecord {
+	rec := articleRecord{ID: int(article.ID), Title: article.Title, Published: article.Published}
+	if article.URL != nil {
+		rec.URL = article.URL.String()
+	}
+	return rec
+}
+
+var ansiColors = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// The error messages are colored for the text output.
+func stripColors(s string) string {
+	return strings.TrimSpace(ansiColors.ReplaceAllString(s, ""))
+}
@@ -0,0 +1,326 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/sethgrid/gencurl"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// DefaultBaseURL is the base URL of the DEV API. Other Forem instances can be
+// used by giving their base URL to NewClient.
+const DefaultBaseURL = "https://dev.to"
+
+// Client talks to the DEV API, or to any other Forem instance.
+type Client struct {
+	// BaseURL is the Forem instance's URL without the trailing slash, e.g.,
+	// "https://dev.to".
+	BaseURL string
+
+	// HTTP sets the API key on every request.
+	HTTP *http.Client
+
+	// lib is used for the endpoints that the devto-api-go library knows
+	// about, such as listing the user's articles.
+	lib *devto.Client
+}
+
+// ClientOptions configures NewClient. The zero value gives a client that
+// paces its requests to the DEV rate limit.
+type ClientOptions struct {
+	// Debug prints each request in curl format.
+	Debug bool
+
+	// RateLimit is the number of requests that can be sent within
+	// RateLimitWindow. Default to DefaultRateLimit per
+	// DefaultRateLimitWindow.
+	RateLimit       int
+	RateLimitWindow time.Duration
+
+	// RetryBudget is how long a request can keep being retried when it is
+	// throttled (429) or fails with a 5xx. Defaults to DefaultRetryBudget.
+	RetryBudget time.Duration
+}
+
+// NewClient returns a client for the Forem instance at baseURL. When baseURL
+// is empty, DefaultBaseURL is used.
+func NewClient(baseURL, apiKey string, opts ClientOptions) (*Client, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if opts.RateLimit == 0 {
+		opts.RateLimit = DefaultRateLimit
+	}
+	if opts.RateLimitWindow == 0 {
+		opts.RateLimitWindow = DefaultRateLimitWindow
+	}
+	if opts.RetryBudget == 0 {
+		opts.RetryBudget = DefaultRetryBudget
+	}
+
+	httpClient := &http.Client{Transport: &transport{
+		wrapped:    http.DefaultTransport,
+		outputCurl: opts.Debug,
+		apiKey:     apiKey,
+		pacer:      newPacer(opts.RateLimit, opts.RateLimitWindow),
+		budget:     opts.RetryBudget,
+	}}
+
+	// The library resolves paths such as "api/articles" against the base
+	// URL, which means that the base URL needs a trailing slash for its path
+	// to be kept.
+	lib, err := devto.NewClient(context.Background(), &devto.Config{
+		APIKey: apiKey,
+	}, httpClient, baseURL+"/")
+	if err != nil {
+		return nil, fmt.Errorf("while creating the devto client for %s: %w", baseURL, err)
+	}
+
+	return &Client{BaseURL: baseURL, HTTP: httpClient, lib: lib}, nil
+}
+
+// Returns all the user's unpublished articles and then the published
+// articles.
+//
+// client.Articles.ListAllMyArticles was not actually listing all articles
+// and would only show the unpublished ones. Also, it would only show the
+// first 20.
+func (c *Client) ListAllMyArticles(ctx context.Context) ([]devto.ListedArticle, error) {
+	// The max. number of items per page is 1000, see:
+	// https://docs.forem.com/api/#tag/articles.
+	articlesUnpublished, err := c.lib.Articles.ListMyUnpublishedArticles(ctx, &devto.MyArticlesOptions{PerPage: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("fetching unpublished articles: %s", err)
+	}
+	articlesPublished, err := c.lib.Articles.ListMyPublishedArticles(ctx, &devto.MyArticlesOptions{PerPage: 1000})
+	if err != nil {
+		return nil, fmt.Errorf("fetching published articles: %s", err)
+	}
+	return append(articlesUnpublished, articlesPublished...), nil
+}
+
+// transport sets the API key on each request, paces the requests to stay
+// under the rate limit, and retries the requests that were throttled or that
+// failed with a 5xx until the retry budget is exhausted.
+type transport struct {
+	wrapped    http.RoundTripper
+	outputCurl bool
+	apiKey     string
+	pacer      *pacer
+	budget     time.Duration
+}
+
+func (rt *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.Header.Set("Accept", "application/json")
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	r.Header.Set("Api-Key", rt.apiKey)
+
+	ctx := r.Context()
+	start := rt.pacer.now()
+	for attempt := 1; ; attempt++ {
+		if err := rt.pacer.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		// The body was consumed by the previous attempt.
+		req := r
+		if attempt > 1 && r.Body != nil {
+			req = r.Clone(ctx)
+			body, err := r.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("while rewinding the request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		if rt.outputCurl {
+			logutil.Debugf("%s", gencurl.FromRequest(req))
+		}
+		resp, err := rt.wrapped.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		rt.pacer.observe(resp.Header)
+
+		now := rt.pacer.now()
+		wait, retry := retryDelay(r.Method, resp, attempt, now)
+		if !retry || (r.Body != nil && r.GetBody == nil) {
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if now.Add(wait).Sub(start) > rt.budget {
+			return nil, &RetryBudgetError{
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Attempts:   attempt,
+				Budget:     rt.budget,
+				LastStatus: resp.Status,
+			}
+		}
+		logutil.Debugf("%s %s: got %s, retrying in %s", r.Method, r.URL.Path, resp.Status, wait.Round(time.Millisecond))
+		if err := rt.pacer.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Get the published article using its ID. Note that it does not work for
+// unpublished articles.
+// https://developers.forem.com/api#operation/getArticleById
+func (c *Client) GetArticle(ctx context.Context, articleID int) (devto.Article, error) {
+	path := fmt.Sprintf("/api/articles/%d", articleID)
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+path, nil)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("creating HTTP request for GET %s: %w", path, err)
+	}
+
+	httpResp, err := c.HTTP.Do(req)
+	if err != nil {
+		// The error is already clear enough without the URL.
+		var budgetErr *RetryBudgetError
+		if errors.As(err, &budgetErr) {
+			return devto.Article{}, budgetErr
+		}
+		return devto.Article{}, fmt.Errorf("while doing %s %s: %w", req.Method, path, err)
+	}
+	defer httpResp.Body.Close()
+
+	bytes, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("while reading HTTP response for %s: %w", path, err)
+	}
+
+	switch httpResp.StatusCode {
+	case 200:
+		// continue below
+	default:
+		err = parseDevtoError(httpResp.StatusCode, bytes)
+		return devto.Article{}, err
+	}
+
+	var art devto.Article
+	err = json.Unmarshal(bytes, &art)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("while parsing JSON from the HTTP response for %s %s: %w", req.Method, path, err)
+	}
+
+	return art, nil
+}
+
+// https://developers.forem.com/api#operation/updateArticle
+func (c *Client) UpdateArticle(ctx context.Context, articleID int, article Article) (devto.Article, error) {
+	return c.sendArticle(ctx, "PUT", fmt.Sprintf("/api/articles/%d", articleID), article, 200)
+}
+
+// Creates a new article. Whether the article is published or stays a draft
+// is decided by the "published" field of the front matter in BodyMarkdown.
+// https://developers.forem.com/api#operation/createArticle
+func (c *Client) CreateArticle(ctx context.Context, article Article) (devto.Article, error) {
+	return c.sendArticle(ctx, "POST", "/api/articles", article, 201)
+}
+
+func (c *Client) sendArticle(ctx context.Context, method, path string, article Article, expectStatus int) (devto.Article, error) {
+	articleReq := ArticleReq{Article: article}
+	raw, err := json.Marshal(&articleReq)
+	if err != nil {
+		panic("unexpected: " + err.Error())
+	}
+	reader := bytes.NewReader(raw)
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("creating HTTP request for %s %s: %w", method, path, err)
+	}
+
+	httpResp, err := c.HTTP.Do(req)
+	if err != nil {
+		// The error is already clear enough without the URL.
+		var budgetErr *RetryBudgetError
+		if errors.As(err, &budgetErr) {
+			return devto.Article{}, budgetErr
+		}
+		return devto.Article{}, fmt.Errorf("while doing %s %s: %w", req.Method, path, err)
+	}
+	defer httpResp.Body.Close()
+
+	bytes, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("while reading HTTP response for %s: %w", path, err)
+	}
+
+	switch httpResp.StatusCode {
+	case expectStatus:
+		// continue below
+	default:
+		err = parseDevtoError(httpResp.StatusCode, bytes)
+		return devto.Article{}, err
+	}
+
+	var art devto.Article
+	err = json.Unmarshal(bytes, &art)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("while parsing JSON from the HTTP response for %s %s: %w", req.Method, path, err)
+	}
+
+	return art, nil
+}
+
+type ArticleReq struct {
+	Article Article `json:"article"`
+}
+
+// Article is what is sent to DEV when creating or updating an article. The
+// fields left empty are not sent, except for Published and BodyMarkdown. Note
+// that DEV gives precedence to the front matter at the top of BodyMarkdown
+// over the other fields.
+type Article struct {
+	Title          string   `json:"title,omitempty"`
+	BodyMarkdown   string   `json:"body_markdown"`
+	Published      bool     `json:"published"`
+	Tags           []string `json:"tags,omitempty"`
+	Series         string   `json:"series,omitempty"`
+	CanonicalURL   string   `json:"canonical_url,omitempty"`
+	Description    string   `json:"description,omitempty"`
+	MainImage      string   `json:"main_image,omitempty"`
+	OrganizationID int      `json:"organization_id,omitempty"`
+}
+
+type DevtoError struct {
+	Err    string `json:"error"`
+	Status int    `json:"status"`
+}
+
+func (e DevtoError) Error() string { return e.Err }
+
+func parseDevtoError(status int, bytes []byte) error {
+	var errResp DevtoError
+	if err := json.Unmarshal(bytes, &errResp); err != nil {
+		return DevtoError{Status: status, Err: strings.TrimSpace(string(bytes))}
+	}
+
+	return errResp
+}
+
+// We want to have "/edit" at the end of URLs that are not yet published
+// since these cannot be accessed without "/edit".
+func AddEditSegment(articleURL string, published bool) string {
+	if !published {
+		articleURL += "/edit"
+	}
+	return articleURL
+}
@@ -0,0 +1,116 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"gopkg.in/yaml.v3"
+)
+
+// compareArticle returns the names of the fields that differ between the DEV
+// article and the article that would be pushed. DEV doesn't list the series
+// nor the organization of the articles, so the series is compared using the
+// front matter at the top of the body, and the organization isn't compared.
+//
+// The front matter is expected to differ when a field differs, so
+// "front_matter" is only reported when nothing else changed, e.g., when the
+// date changed.
+func compareArticle(remote *devto.ListedArticle, want Article) []string {
+	var changes []string
+	if remote.Title != want.Title {
+		changes = append(changes, "title")
+	}
+	if remote.Published != want.Published {
+		changes = append(changes, "published")
+	}
+	if !sameTags(remote.TagList, want.Tags) {
+		changes = append(changes, "tags")
+	}
+
+	// DEV generates a description and a canonical URL when none is given.
+	if want.Description != "" && remote.Description != want.Description {
+		changes = append(changes, "description")
+	}
+	if want.CanonicalURL != "" && webURLString(remote.CanonicalURL) != want.CanonicalURL {
+		changes = append(changes, "canonical_url")
+	}
+	if !sameImage(webURLString(remote.CoverImage), want.MainImage) {
+		changes = append(changes, "main_image")
+	}
+
+	remoteFM, remoteBody := splitFrontMatter(remote.BodyMarkdown)
+	wantFM, wantBody := splitFrontMatter(want.BodyMarkdown)
+	if frontMatterValue(remoteFM, "series") != frontMatterValue(wantFM, "series") {
+		changes = append(changes, "series")
+	}
+	if remoteBody != wantBody {
+		changes = append(changes, "body")
+	}
+	if len(changes) == 0 && remoteFM != wantFM {
+		changes = append(changes, "front_matter")
+	}
+	return changes
+}
+
+// DEV doesn't keep the order of the tags, so the tags are compared
+// regardless of their order.
+func sameTags(remote, want []string) bool {
+	remote, want = slices.Clone(remote), slices.Clone(want)
+	slices.Sort(remote)
+	slices.Sort(want)
+	return slices.Equal(remote, want)
+}
+
+// DEV may serve the cover image through its image CDN, in which case the
+// original URL is kept at the end of the CDN URL, sometimes escaped.
+func sameImage(remote, want string) bool {
+	switch {
+	case want == "":
+		return remote == ""
+	case remote == want:
+		return true
+	case strings.HasSuffix(remote, want):
+		return true
+	case strings.HasSuffix(remote, url.QueryEscape(want)):
+		return true
+	}
+	return false
+}
+
+func webURLString(u *devto.WebURL) string {
+	if u == nil || u.URL == nil {
+		return ""
+	}
+	return u.String()
+}
+
+// splitFrontMatter splits a DEV body into its front matter (without the ---
+// lines) and the rest. The front matter is empty when the body doesn't start
+// with one.
+func splitFrontMatter(body string) (string, string) {
+	if !strings.HasPrefix(body, "---\n") {
+		return "", body
+	}
+	end := strings.Index(body[4:], "\n---\n")
+	if end == -1 {
+		return "", body
+	}
+	return body[4 : 4+end+1], body[4+end+5:]
+}
+
+// frontMatterValue returns the value of a top-level key of a YAML front
+// matter, or an empty string when the key is missing, when the value is "",
+// or when the front matter isn't valid YAML.
+func frontMatterValue(fm, key string) string {
+	var fields map[string]any
+	if err := yaml.Unmarshal([]byte(fm), &fields); err != nil {
+		return ""
+	}
+	if fields[key] == nil {
+		return ""
+	}
+	return fmt.Sprint(fields[key])
+}
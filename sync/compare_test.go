@@ -0,0 +1,62 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_compareArticle(t *testing.T) {
+	body := func(fm string) string {
+		return "---\n" + fm + "---\n\nHello world.\n"
+	}
+	remote := func(edit func(*devto.ListedArticle)) *devto.ListedArticle {
+		art := &devto.ListedArticle{
+			Title:        "Foo",
+			Published:    true,
+			TagList:      devto.Tags{"go", "hugo"},
+			Description:  "About foo.",
+			CanonicalURL: webURL(t, "https://blog.example.com/foo/"),
+			CoverImage:   webURL(t, "https://res.cloudinary.com/practicaldev/image/fetch/s--x--/https://blog.example.com/foo/cover.png"),
+			BodyMarkdown: body("title: \"Foo\"\nseries: \"\"\n"),
+		}
+		if edit != nil {
+			edit(art)
+		}
+		return art
+	}
+	want := Article{
+		Title:        "Foo",
+		Published:    true,
+		Tags:         []string{"go", "hugo"},
+		Description:  "About foo.",
+		CanonicalURL: "https://blog.example.com/foo/",
+		MainImage:    "https://blog.example.com/foo/cover.png",
+		BodyMarkdown: body("title: \"Foo\"\nseries: \"\"\n"),
+	}
+
+	tests := []struct {
+		name   string
+		remote *devto.ListedArticle
+		want   []string
+	}{
+		{"no change", remote(nil), nil},
+		{"tag-only change", remote(func(a *devto.ListedArticle) { a.TagList = devto.Tags{"go"} }), []string{"tags"}},
+		{"tag order", remote(func(a *devto.ListedArticle) { a.TagList = devto.Tags{"hugo", "go"} }), nil},
+		{"unpublished on DEV", remote(func(a *devto.ListedArticle) { a.Published = false }), []string{"published"}},
+		{"description", remote(func(a *devto.ListedArticle) { a.Description = "Old." }), []string{"description"}},
+		{"canonical URL", remote(func(a *devto.ListedArticle) { a.CanonicalURL = webURL(t, "https://dev.to/foo") }), []string{"canonical_url"}},
+		{"cover image served as is", remote(func(a *devto.ListedArticle) { a.CoverImage = webURL(t, "https://blog.example.com/foo/cover.png") }), nil},
+		{"cover image removed", remote(func(a *devto.ListedArticle) { a.CoverImage = nil }), []string{"main_image"}},
+		{"series", remote(func(a *devto.ListedArticle) { a.BodyMarkdown = body("title: \"Foo\"\nseries: \"Bar\"\n") }), []string{"series"}},
+		{"body", remote(func(a *devto.ListedArticle) { a.BodyMarkdown = "---\ntitle: \"Foo\"\nseries: \"\"\n---\n\nHello.\n" }), []string{"body"}},
+		{"other front matter field", remote(func(a *devto.ListedArticle) { a.BodyMarkdown = body("title: \"Foo\"\nseries: \"\"\ndate: 2024\n") }), []string{"front_matter"}},
+		{"no front matter on DEV", remote(func(a *devto.ListedArticle) { a.BodyMarkdown = "\nHello world.\n" }), []string{"front_matter"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, compareArticle(tt.remote, want))
+		})
+	}
+}
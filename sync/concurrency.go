@@ -0,0 +1,32 @@
+package sync
+
+// inOrder calls do for each index from 0 to n-1 with up to concurrency calls
+// running at the same time. The report func is called with the results in
+// index order, as soon as the result and all the ones before it are
+// available, which keeps the output deterministic. A concurrency lower than 1
+// is treated as 1.
+func inOrder[T any](n, concurrency int, do func(i int) T, report func(i int, result T)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]chan T, n)
+	for i := range results {
+		results[i] = make(chan T, 1)
+	}
+
+	go func() {
+		sem := make(chan struct{}, concurrency)
+		for i := range n {
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem }()
+				results[i] <- do(i)
+			}()
+		}
+	}()
+
+	for i := range results {
+		report(i, <-results[i])
+	}
+}
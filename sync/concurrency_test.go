@@ -0,0 +1,33 @@
+package sync
+
+import (
+	"math/rand/v2"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_inOrder(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	var got []int
+	inOrder(20, 4, func(i int) int {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			m := maxRunning.Load()
+			if n <= m || maxRunning.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(time.Duration(rand.N(5)) * time.Millisecond)
+		return i * 10
+	}, func(i int, result int) {
+		assert.Equal(t, i*10, result)
+		got = append(got, i)
+	})
+
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}, got)
+	assert.LessOrEqual(t, maxRunning.Load(), int32(4))
+}
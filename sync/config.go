@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gohugoio/hugo/common/maps"
+	"github.com/gohugoio/hugo/hugolib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// ConfigFile is the name of the hudevto config file, looked up in the root
+// directory of the Hugo project.
+const ConfigFile = "hudevto.yaml"
+
+// Config is the hudevto config. It is read from the hudevto.yaml file at the
+// root of the Hugo project and, when that file doesn't exist, from the
+// params.hudevto of the site's config, e.g.,
+//
+//	params:
+//	  hudevto:
+//	    tags:
+//	      aliases:
+//	        kubernetes: k8s
+//	      priority: [go, k8s]
+//	    transformers:
+//	      - name: unwrap-soft-breaks
+//	      - name: anchor-ids
+//
+// Hugo lowercases the keys of the params, so the transformers' params that
+// aren't all lowercase should be given in hudevto.yaml.
+type Config struct {
+	// Tags tells how the keywords of the posts become DEV tags.
+	Tags TagRules `yaml:"tags"`
+
+	// Transformers is the list of transformations applied to the body of the
+	// posts, in order. When nil, DefaultTransformers is used.
+	Transformers []TransformerConfig `yaml:"transformers"`
+}
+
+// LoadConfig reads the hudevto.yaml found in rootDir or, when missing, the
+// params.hudevto of the site. The zero Config is returned when there is no
+// config at all.
+func LoadConfig(rootDir string, sites *hugolib.HugoSites) (Config, error) {
+	path := filepath.Join(rootDir, ConfigFile)
+	bytes, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return Config{}, fmt.Errorf("while reading %s: %w", path, err)
+	default:
+		logutil.Debugf("using config file %s", logutil.Gray(path))
+		cfg, err := decodeConfig(bytes)
+		if err != nil {
+			return Config{}, fmt.Errorf("while parsing %s: %w", path, err)
+		}
+		return cfg, nil
+	}
+
+	if sites == nil || len(sites.Sites) == 0 {
+		return Config{}, nil
+	}
+	return configFromParams(sites.Sites[0].Params())
+}
+
+func configFromParams(params maps.Params) (Config, error) {
+	raw, ok := params["hudevto"]
+	if !ok {
+		return Config{}, nil
+	}
+
+	// Hugo gives the params as maps of any, going through YAML is the easiest
+	// way to check the types.
+	bytes, err := yaml.Marshal(raw)
+	if err != nil {
+		return Config{}, fmt.Errorf("while reading params.hudevto: %w", err)
+	}
+	cfg, err := decodeConfig(bytes)
+	if err != nil {
+		return Config{}, fmt.Errorf("while reading params.hudevto: %w", err)
+	}
+	return cfg, nil
+}
+
+// decodeConfig rejects the unknown fields so that a typo, e.g., "transformer"
+// instead of "transformers", doesn't go unnoticed. Like decodeParams does for
+// the params of the transformers.
+func decodeConfig(bytes []byte) (Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(strings.NewReader(string(bytes)))
+	dec.KnownFields(true)
+	err := dec.Decode(&cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, err
+	}
+	return cfg, nil
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gohugoio/hugo/common/maps"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		given     string
+		expect    Config
+		expectErr string
+	}{
+		{
+			name: "tags and transformers",
+			given: `
+tags:
+  aliases:
+    kubernetes: k8s
+transformers:
+  - name: anchor-ids
+`,
+			expect: Config{
+				Tags:         TagRules{Aliases: map[string]string{"kubernetes": "k8s"}},
+				Transformers: []TransformerConfig{{Name: "anchor-ids"}},
+			},
+		},
+		{
+			name:   "empty file",
+			given:  "",
+			expect: Config{},
+		},
+		{
+			name:      "unknown field",
+			given:     "transformer:\n  - name: anchor-ids\n",
+			expectErr: "field transformer not found in type sync.Config",
+		},
+		{
+			name:      "unknown field in a transformer",
+			given:     "transformers:\n  - name: anchor-ids\n    param: {}\n",
+			expectErr: "field param not found in type sync.TransformerConfig",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(root, ConfigFile), []byte(tt.given), 0644))
+			got, err := LoadConfig(root, nil)
+			if tt.expectErr != "" {
+				assert.ErrorContains(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func TestLoadConfig_siteParams(t *testing.T) {
+	_, sites := loadTestSite(t, map[string]string{
+		"hugo.yaml": readFile(t, "../testdata/site/hugo.yaml") + `
+params:
+  hudevto:
+    transformers:
+      - name: unwrap-soft-breaks
+        enabled: false
+`,
+	})
+	disabled := false
+	got, err := LoadConfig(t.TempDir(), sites)
+	require.NoError(t, err)
+	assert.Equal(t, Config{Transformers: []TransformerConfig{{Name: TransformUnwrapSoftBreaks, Enabled: &disabled}}}, got)
+}
+
+func Test_configFromParams(t *testing.T) {
+	_, err := configFromParams(maps.Params{"hudevto": maps.Params{"tag": maps.Params{}}})
+	assert.ErrorContains(t, err, "while reading params.hudevto: yaml: unmarshal errors:\n  line 1: field tag not found in type sync.Config")
+}
@@ -0,0 +1,99 @@
+package sync
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/gohugoio/hugo/common/maps"
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// The front matter fields that may hold the cover image of a post, in order of
+// precedence. devtoCover lets a post use a different cover on DEV than on the
+// blog. The cover and featured_image fields are the ones used by the popular
+// Hugo themes, e.g., PaperMod uses cover.image and Ananke uses
+// featured_image. The images field is the one used by Hugo's internal
+// templates for the Open Graph and Twitter cards.
+var coverFields = []string{"devtoCover", "cover", "featured_image", "images"}
+
+// The page bundle resources used as the cover when none of the coverFields is
+// set, in order of precedence.
+var coverResources = []string{"cover.*", "featured.*"}
+
+// pageCover returns the URL of the cover image of the post, or an empty string
+// when the post has no cover. The cover is looked up in the coverFields, then
+// in the resources of the page bundle, see coverResources.
+//
+// The fields can either be a string or a list, in which case the first image
+// is used. A relative path is looked up in the resources of the page bundle,
+// e.g., cover.png, and is otherwise relative to the root of the site, e.g.,
+// images/cover.png.
+func pageCover(sites *hugolib.HugoSites, pg page.Page) (string, error) {
+	for _, field := range coverFields {
+		raw, err := pg.Param(field)
+		if err != nil || raw == nil {
+			continue
+		}
+		img, err := coverParam(raw)
+		if err != nil {
+			return "", fmt.Errorf("field %s %w", field, err)
+		}
+		if img == "" {
+			continue
+		}
+		return coverURL(sites, pg, img), nil
+	}
+
+	for _, pattern := range coverResources {
+		res := pg.Resources().GetMatch(pattern)
+		if res != nil && res.ResourceType() == "image" {
+			return res.Permalink(), nil
+		}
+	}
+	return "", nil
+}
+
+// coverParam returns the image given in a cover field, or an empty string
+// when the field is empty. The field is either a string, a list of strings, or
+// a map with an "image" key like PaperMod's cover.
+func coverParam(raw any) (string, error) {
+	switch v := raw.(type) {
+	case string:
+		return v, nil
+	case []string:
+		if len(v) == 0 {
+			return "", nil
+		}
+		return v[0], nil
+	case []any:
+		if len(v) == 0 {
+			return "", nil
+		}
+		img, ok := v[0].(string)
+		if !ok {
+			return "", fmt.Errorf("is expected to be a list of strings, got '%T' in the list", v[0])
+		}
+		return img, nil
+	case maps.Params:
+		return coverParam(map[string]any(v))
+	case map[string]any:
+		img, ok := v["image"].(string)
+		if !ok && v["image"] != nil {
+			return "", fmt.Errorf("is expected to have an image of type string, got '%T'", v["image"])
+		}
+		return img, nil
+	default:
+		return "", fmt.Errorf("is expected to be a string, a list of strings or a map with an image key, got '%T'", raw)
+	}
+}
+
+func coverURL(sites *hugolib.HugoSites, pg page.Page, img string) string {
+	if u, err := url.Parse(img); err == nil && u.IsAbs() {
+		return img
+	}
+	if res := pg.Resources().GetMatch(img); res != nil {
+		return res.Permalink()
+	}
+	return sites.AbsURL(img, false)
+}
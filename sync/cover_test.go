@@ -0,0 +1,106 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_pageCover(t *testing.T) {
+	tests := []struct {
+		name        string
+		frontMatter string
+		files       []string // Resources of the page bundle.
+		expect      string
+		expectErr   string
+	}{
+		{
+			name:        "images",
+			frontMatter: "images: [images/a.png, images/b.png]",
+			expect:      "https://blog.example.com/images/a.png",
+		},
+		{
+			name:        "images as a string",
+			frontMatter: "images: images/a.png",
+			expect:      "https://blog.example.com/images/a.png",
+		},
+		{
+			name:        "empty images",
+			frontMatter: "images: []",
+			expect:      "",
+		},
+		{
+			name:        "cover in the page bundle",
+			frontMatter: "cover: diagram.png\nimages: [images/a.png]",
+			files:       []string{"diagram.png"},
+			expect:      "https://blog.example.com/posts/cover-in-the-page-bundle/diagram.png",
+		},
+		{
+			name:        "cover as a map",
+			frontMatter: "cover:\n  image: https://cdn.example.com/cover.png\n  alt: A cover",
+			expect:      "https://cdn.example.com/cover.png",
+		},
+		{
+			name:        "featured_image",
+			frontMatter: "featured_image: /images/featured.png",
+			expect:      "https://blog.example.com/images/featured.png",
+		},
+		{
+			name:        "devto cover overrides the other fields",
+			frontMatter: "devtoCover: devto.png\ncover: cover.png",
+			files:       []string{"devto.png", "cover.png"},
+			expect:      "https://blog.example.com/posts/devto-cover-overrides-the-other-fields/devto.png",
+		},
+		{
+			name:   "cover resource",
+			files:  []string{"featured.jpg", "cover.png"},
+			expect: "https://blog.example.com/posts/cover-resource/cover.png",
+		},
+		{
+			name:   "featured resource",
+			files:  []string{"featured.jpg", "notes.txt"},
+			expect: "https://blog.example.com/posts/featured-resource/featured.jpg",
+		},
+		{
+			name:   "no cover",
+			files:  []string{"cover.txt"},
+			expect: "",
+		},
+		{
+			name:        "invalid field",
+			frontMatter: "cover: 42",
+			expectErr:   "field cover is expected to be a string, a list of strings or a map with an image key, got 'int'",
+		},
+	}
+
+	files := make(map[string]string)
+	dirOf := func(name string) string {
+		return "content/posts/" + strings.ReplaceAll(name, " ", "-")
+	}
+	for _, tt := range tests {
+		files[dirOf(tt.name)+"/index.md"] = "---\n" +
+			"title: " + tt.name + "\n" +
+			tt.frontMatter + "\n" +
+			"---\n"
+		for _, file := range tt.files {
+			files[dirOf(tt.name)+"/"+file] = file
+		}
+	}
+	_, sites := loadTestSite(t, files)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := sites.GetContentPage("/" + dirOf(tt.name) + "/index.md")
+			require.NotNil(t, pg)
+			got, err := pageCover(sites, pg)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
@@ -0,0 +1,430 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maelvls/hudevto/devtotest"
+)
+
+// The sample Hugo site in ../testdata/site contains:
+//
+//	content/posts/published.md         devtoId 1001, published
+//	content/posts/with-image/index.md  devtoId 1002, unpublished
+//	content/posts/new.md               no devtoId
+//	content/posts/draft.md             draft, not loaded by Hugo
+//	content/posts/skipped.md           devtoSkip
+func Test_EndToEnd(t *testing.T) {
+	srv := devtotest.NewServer(t)
+	srv.AddArticle(devtotest.Article{ID: 1001, Title: "Published post", Published: true, BodyMarkdown: "outdated 1"})
+	srv.AddArticle(devtotest.Article{ID: 1002, Title: "Post with an image", BodyMarkdown: "outdated 2"})
+
+	t.Run("status shows what would be pushed", func(t *testing.T) {
+		root := copySite(t)
+		plan, err := newTestPlanner(t, srv, root, true).Plan(context.Background(), "")
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"content/posts/new.md: create (not-on-devto)",
+			"content/posts/published.md: push (changed)",
+			"content/posts/skipped.md: skip (devto-skip)",
+			"content/posts/with-image/index.md: push (changed)",
+		}, summarize(root, plan))
+	})
+
+	t.Run("status without --create fails on posts without devtoId", func(t *testing.T) {
+		root := copySite(t)
+		plan, err := newTestPlanner(t, srv, root, false).Plan(context.Background(), "content/posts/new.md")
+		require.NoError(t, err)
+
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, ActionError, plan.Posts[0].Action)
+		assert.Equal(t, ReasonMissingID, plan.Posts[0].Reason)
+	})
+
+	t.Run("diff shows the transformed Markdown", func(t *testing.T) {
+		root := copySite(t)
+		plan, err := newTestPlanner(t, srv, root, false).Plan(context.Background(), "content/posts/with-image/index.md")
+		require.NoError(t, err)
+
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, "outdated 2", plan.Posts[0].Remote.BodyMarkdown)
+		assert.Contains(t, plan.Posts[0].Markdown, "![Setup](https://blog.example.com/posts/with-image/setup.png)")
+	})
+
+	t.Run("the tags follow the rules of the config", func(t *testing.T) {
+		root := copySite(t)
+		config := filepath.Join(root, "hugo.yaml")
+		require.NoError(t, os.WriteFile(config, []byte(readFile(t, config)+`
+params:
+  hudevto:
+    tags:
+      aliases:
+        kubernetes: k8s
+      priority: [k8s]
+`), 0644))
+		newMD := filepath.Join(root, "content/posts/new.md")
+		content := strings.Replace(readFile(t, newMD), "keywords: [hugo]", "keywords: [hugo, Go, blog, web-dev, Kubernetes]", 1)
+		require.NoError(t, os.WriteFile(newMD, []byte(content), 0644))
+
+		plan, err := newTestPlanner(t, srv, root, true).Plan(context.Background(), "content/posts/new.md")
+		require.NoError(t, err)
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, []string{"k8s", "hugo", "go", "blog"}, plan.Posts[0].Article.Tags)
+		assert.Equal(t, []string{"web-dev"}, plan.Posts[0].DroppedTags)
+		assert.Contains(t, plan.Posts[0].Markdown, `tags: "k8s, hugo, go, blog"`)
+
+		// The config given to the planner wins over the params.
+		planner := newTestPlanner(t, srv, root, true)
+		planner.Config = &Config{}
+		plan, err = planner.Plan(context.Background(), "content/posts/new.md")
+		require.NoError(t, err)
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, []string{"hugo", "go", "blog", "webdev"}, plan.Posts[0].Article.Tags)
+	})
+
+	t.Run("a post is checked against the rest of its series", func(t *testing.T) {
+		root := copySite(t)
+		setFrontMatter := func(rel, line string) {
+			t.Helper()
+			path := filepath.Join(root, rel)
+			content := strings.Replace(readFile(t, path), "\ndraft: false\n", "\ndraft: false\n"+line+"\n", 1)
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		}
+		setFrontMatter("content/posts/published.md", "series: Hugo to DEV")
+		setFrontMatter("content/posts/with-image/index.md", "series: [Hugo to DEV]")
+		setFrontMatter("content/posts/new.md", "series: hugo to dev")
+
+		plan, err := newTestPlanner(t, srv, root, true).Plan(context.Background(), "content/posts/published.md")
+		require.NoError(t, err)
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, "Hugo to DEV", plan.Posts[0].Article.Series)
+		assert.Contains(t, plan.Posts[0].Changes, "series")
+
+		// Even when a single post is planned, it is checked against the other
+		// posts of the series.
+		plan, err = newTestPlanner(t, srv, root, true).Plan(context.Background(), "content/posts/new.md")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"content/posts/new.md: error (series-mismatch)"}, summarize(root, plan))
+	})
+
+	t.Run("the images are only uploaded once the post passed the checks", func(t *testing.T) {
+		root := copySite(t)
+		require.NoError(t, os.MkdirAll(filepath.Join(root, "static/images"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "static/images/logo.png"), []byte("logo"), 0644))
+		indexMD := filepath.Join(root, "content/posts/with-image/index.md")
+		require.NoError(t, os.WriteFile(indexMD, []byte(strings.Replace(readFile(t, indexMD),
+			"The picture above was taken last week.",
+			"![Logo](/images/logo.png), [see below](#nope).", 1)), 0644))
+
+		dir := map[string]any{"backend": "dir", "dir": map[string]any{"path": "uploads", "url": "https://cdn.example.com/"}}
+		plan := func(t *testing.T, push bool, transformers ...TransformerConfig) PostPlan {
+			t.Helper()
+			planner := newTestPlanner(t, srv, root, false)
+			planner.Push = push
+			planner.Config = &Config{Transformers: append([]TransformerConfig{
+				{Name: TransformUploadImages, Params: dir},
+				{Name: TransformAbsoluteURLs},
+			}, transformers...)}
+			plan, err := planner.Plan(context.Background(), "content/posts/with-image/index.md")
+			require.NoError(t, err)
+			require.Len(t, plan.Posts, 1)
+			return plan.Posts[0]
+		}
+
+		// Until they are uploaded, the images point to the blog.
+		post := plan(t, false)
+		require.NoError(t, post.Err)
+		assert.Contains(t, post.Markdown, "![Logo](https://blog.example.com/images/logo.png)")
+		assert.Equal(t, []string{"setup.png", "/images/logo.png"}, post.PendingImages)
+		assert.Contains(t, post.Changes, "body")
+		assert.NoDirExists(t, filepath.Join(root, "uploads"))
+
+		post = plan(t, true, TransformerConfig{Name: "test-fail"})
+		assert.Equal(t, ActionError, post.Action)
+		assert.NoDirExists(t, filepath.Join(root, "uploads"))
+
+		// The post is rendered twice, but its warnings are only reported once.
+		post = plan(t, true, TransformerConfig{Name: TransformAnchorIDs})
+		require.NoError(t, post.Err)
+		assert.Contains(t, post.Markdown, "![Logo](https://cdn.example.com/3598ce6f965b2481-logo.png)")
+		assert.Empty(t, post.PendingImages)
+		var levels []DiagnosticLevel
+		for _, d := range post.Diagnostics {
+			levels = append(levels, d.Level)
+		}
+		assert.Equal(t, []DiagnosticLevel{DiagnosticInfo, DiagnosticInfo, DiagnosticError}, levels)
+	})
+
+	t.Run("push then status shows no change", func(t *testing.T) {
+		root := copySite(t)
+		plan, err := newTestPlanner(t, srv, root, true).Plan(context.Background(), "")
+		require.NoError(t, err)
+
+		var pushed []string
+		executor := Executor{Client: newTestClient(t, srv)}
+		executor.Execute(context.Background(), plan, func(res Result) {
+			require.NoError(t, res.Err)
+			if res.Post.Action == ActionPush || res.Post.Action == ActionCreate {
+				pushed = append(pushed, rel(root, res.Post.Path))
+			}
+		})
+		sort.Strings(pushed)
+		assert.Equal(t, []string{"content/posts/new.md", "content/posts/published.md", "content/posts/with-image/index.md"}, pushed)
+
+		// The created article's ID and URL are written to the front matter.
+		created := srv.Articles()[2]
+		assert.Equal(t, "Brand new post", created.Title)
+		assert.False(t, created.Published)
+		newMD := readFile(t, filepath.Join(root, "content/posts/new.md"))
+		assert.Contains(t, newMD, "\ndevtoId: 1003\n")
+		assert.Contains(t, newMD, "\ndevtoUrl: "+srv.URLOf(created)+"\n")
+
+		// Hugo needs to load the site again since the front matter changed.
+		plan, err = newTestPlanner(t, srv, root, true).Plan(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"content/posts/new.md: skip (no-change)",
+			"content/posts/published.md: skip (no-change)",
+			"content/posts/skipped.md: skip (devto-skip)",
+			"content/posts/with-image/index.md: skip (no-change)",
+		}, summarize(root, plan))
+
+		// A tag-only change is pushed as such.
+		publishedMD := filepath.Join(root, "content/posts/published.md")
+		content := strings.Replace(readFile(t, publishedMD), "keywords: [go, hugo]", "keywords: [go]", 1)
+		require.NoError(t, os.WriteFile(publishedMD, []byte(content), 0644))
+		plan, err = newTestPlanner(t, srv, root, false).Plan(context.Background(), "content/posts/published.md")
+		require.NoError(t, err)
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, ActionPush, plan.Posts[0].Action)
+		assert.Equal(t, []string{"tags"}, plan.Posts[0].Changes)
+
+		executor.Execute(context.Background(), plan, func(res Result) {
+			require.NoError(t, res.Err)
+		})
+		art, _ := srv.Article(1001)
+		assert.Equal(t, []string{"go"}, art.Tags)
+
+		// A post whose title was changed is updated on DEV since its devtoId
+		// tells which article it is.
+		content = strings.Replace(readFile(t, publishedMD), "title: Published post", "title: Renamed post", 1)
+		require.NoError(t, os.WriteFile(publishedMD, []byte(content), 0644))
+		plan, err = newTestPlanner(t, srv, root, false).Plan(context.Background(), "content/posts/published.md")
+		require.NoError(t, err)
+		require.Len(t, plan.Posts, 1)
+		assert.Equal(t, ActionPush, plan.Posts[0].Action)
+		assert.Equal(t, []string{"title"}, plan.Posts[0].Changes)
+
+		executor.Execute(context.Background(), plan, func(res Result) {
+			require.NoError(t, res.Err)
+		})
+		art, _ = srv.Article(1001)
+		assert.Equal(t, "Renamed post", art.Title)
+	})
+
+	// Hugo decodes the devtoId written to a TOML front matter as an int64 and
+	// the one written to a JSON front matter as a float64.
+	t.Run("the integers of TOML and JSON front matter are read", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		root := copySite(t)
+		require.NoError(t, os.WriteFile(filepath.Join(root, "content/posts/toml.md"), []byte(""+
+			"+++\n"+
+			"title = \"TOML post\"\n"+
+			"date = 2024-03-05T10:00:00Z\n"+
+			"keywords = [\"hugo\"]\n"+
+			"devtoPublished = false\n"+
+			"+++\n"+
+			"\n"+
+			"Written in TOML.\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(root, "content/posts/json.md"), []byte(""+
+			"{\n"+
+			"  \"title\": \"JSON post\",\n"+
+			"  \"date\": \"2024-03-06T10:00:00Z\",\n"+
+			"  \"keywords\": [\"hugo\"],\n"+
+			"  \"devtoPublished\": false\n"+
+			"}\n"+
+			"\n"+
+			"Written in JSON.\n"), 0644))
+
+		for _, path := range []string{"content/posts/toml.md", "content/posts/json.md"} {
+			plan, err := newTestPlanner(t, srv, root, true).Plan(context.Background(), path)
+			require.NoError(t, err)
+			require.Len(t, plan.Posts, 1)
+			require.Equal(t, ActionCreate, plan.Posts[0].Action)
+			executor := Executor{Client: newTestClient(t, srv)}
+			executor.Execute(context.Background(), plan, func(res Result) {
+				require.NoError(t, res.Err)
+			})
+
+			plan, err = newTestPlanner(t, srv, root, false).Plan(context.Background(), path)
+			require.NoError(t, err)
+			require.Len(t, plan.Posts, 1)
+			assert.NoError(t, plan.Posts[0].Err)
+			assert.Equal(t, ActionSkip, plan.Posts[0].Action, path)
+			assert.Equal(t, ReasonNoChange, plan.Posts[0].Reason, path)
+		}
+	})
+
+	t.Run("the results are reported in the order of the plan", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		srv.AddArticle(devtotest.Article{ID: 1001, Title: "Published post", Published: true, BodyMarkdown: "outdated 1"})
+		srv.AddArticle(devtotest.Article{ID: 1002, Title: "Post with an image", BodyMarkdown: "outdated 2"})
+
+		root := copySite(t)
+		planner := newTestPlanner(t, srv, root, true)
+		planner.Concurrency = 4
+		plan, err := planner.Plan(context.Background(), "")
+		require.NoError(t, err)
+
+		var reported []string
+		executor := Executor{Client: newTestClient(t, srv), Concurrency: 4}
+		executor.Execute(context.Background(), plan, func(res Result) {
+			require.NoError(t, res.Err)
+			reported = append(reported, res.Post.Path)
+		})
+		var planned []string
+		for _, post := range plan.Posts {
+			planned = append(planned, post.Path)
+		}
+		assert.Equal(t, planned, reported)
+
+		planner = newTestPlanner(t, srv, root, true)
+		planner.Concurrency = 4
+		plan, err = planner.Plan(context.Background(), "")
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"content/posts/new.md: skip (no-change)",
+			"content/posts/published.md: skip (no-change)",
+			"content/posts/skipped.md: skip (devto-skip)",
+			"content/posts/with-image/index.md: skip (no-change)",
+		}, summarize(root, plan))
+	})
+
+	t.Run("a throttled push is retried", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		srv.AddArticle(devtotest.Article{ID: 1001, Title: "Published post", Published: true, BodyMarkdown: "outdated"})
+
+		root := copySite(t)
+		plan, err := newTestPlanner(t, srv, root, false).Plan(context.Background(), "content/posts/published.md")
+		require.NoError(t, err)
+
+		srv.Throttle(1, 0)
+		executor := Executor{Client: newTestClient(t, srv)}
+		executor.Execute(context.Background(), plan, func(res Result) {
+			assert.NoError(t, res.Err)
+		})
+
+		art, _ := srv.Article(1001)
+		assert.Equal(t, plan.Posts[0].Markdown, art.BodyMarkdown)
+	})
+
+	t.Run("the validation errors of DEV are reported", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		root := copySite(t)
+		srv.AddArticle(devtotest.Article{ID: 1001, Title: "Someone else's post", CanonicalURL: "https://blog.example.com/posts/new/"})
+
+		plan, err := newTestPlanner(t, srv, root, true).Plan(context.Background(), "content/posts/new.md")
+		require.NoError(t, err)
+
+		executor := Executor{Client: newTestClient(t, srv)}
+		executor.Execute(context.Background(), plan, func(res Result) {
+			assert.EqualError(t, res.Err, "creating devto article: Validation failed: Canonical url has already been taken")
+		})
+		assert.Len(t, srv.Articles(), 1)
+	})
+}
+
+func newTestClient(t *testing.T, srv *devtotest.Server) *Client {
+	t.Helper()
+	client, err := NewClient(srv.URL, srv.APIKey, ClientOptions{})
+	require.NoError(t, err)
+	return client
+}
+
+func newTestPlanner(t *testing.T, srv *devtotest.Server, root string, create bool) *Planner {
+	t.Helper()
+	sites, err := LoadSites(root, LoadOptions{})
+	require.NoError(t, err)
+	return &Planner{RootDir: root, Sites: sites, Client: newTestClient(t, srv), Create: create}
+}
+
+// copySite copies the sample Hugo site to a temporary directory since
+// pushing writes to the front matter of the posts.
+func copySite(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	require.NoError(t, os.CopyFS(root, os.DirFS("../testdata/site")))
+	return root
+}
+
+// loadTestSite copies the sample Hugo site, adds the given files to it and
+// loads it. The files are given by their path relative to the root of the
+// site, e.g., "content/posts/foo.md".
+func loadTestSite(t *testing.T, files map[string]string) (string, *hugolib.HugoSites) {
+	t.Helper()
+	root := copySite(t)
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	sites, err := LoadSites(root, LoadOptions{})
+	require.NoError(t, err)
+	return root, sites
+}
+
+// testPost returns a post whose body starts on line 7. The RawContent of the
+// page also includes the empty line that comes before the body.
+func testPost(body string) string {
+	return "---\n" +
+		"title: Test post\n" +
+		"date: 2024-03-04T10:00:00Z\n" +
+		"devtoPublished: false\n" +
+		"---\n" +
+		"\n" +
+		body
+}
+
+// newTestContext returns the TransformContext that the Planner gives to the
+// transformers for the post at the given path, e.g., "content/posts/new.md".
+func newTestContext(t *testing.T, root string, sites *hugolib.HugoSites, relPath string) TransformContext {
+	t.Helper()
+	pg := sites.GetContentPage("/" + relPath)
+	require.NotNil(t, pg, "page %s not found", relPath)
+	pathToMD := filepath.Join(root, relPath)
+	return TransformContext{
+		Context:    context.Background(),
+		Page:       pg,
+		Path:       pathToMD,
+		LineOffset: bodyLineOffset(pathToMD, pg.RawContent()),
+		RootDir:    root,
+		Sites:      sites,
+	}
+}
+
+// summarize returns one "path: action (reason)" line per post, sorted by path.
+func summarize(root string, plan *Plan) []string {
+	var lines []string
+	for _, post := range plan.Posts {
+		lines = append(lines, rel(root, post.Path)+": "+string(post.Action)+" ("+string(post.Reason)+")")
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func rel(root, path string) string {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
@@ -0,0 +1,105 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// Result is the outcome of executing the plan of a single post.
+type Result struct {
+	Post *PostPlan
+
+	// Article is the DEV article as returned by DEV after the push. It is
+	// only set when the post was pushed successfully.
+	Article devto.Article
+
+	// Err is set when the push failed.
+	Err error
+}
+
+// Executor applies a Plan by pushing the posts that have changes to DEV.
+type Executor struct {
+	// Client paces the pushes and retries the ones that get throttled, see
+	// ClientOptions.
+	Client *Client
+
+	// Concurrency is the number of posts pushed at the same time. The pushes
+	// share the client's rate limit. Defaults to 1.
+	Concurrency int
+}
+
+// Execute pushes the posts of the plan that have the action ActionPush or
+// ActionCreate. The report func is called once for every post of the plan,
+// including the ones that weren't pushed, in the order of the plan regardless
+// of the concurrency.
+func (e *Executor) Execute(ctx context.Context, plan *Plan, report func(Result)) {
+	inOrder(len(plan.Posts), e.Concurrency, func(i int) Result {
+		post := &plan.Posts[i]
+		switch post.Action {
+		case ActionPush:
+			art, err := e.Push(ctx, post)
+			return Result{Post: post, Article: art, Err: err}
+		case ActionCreate:
+			art, err := e.Create(ctx, post)
+			return Result{Post: post, Article: art, Err: err}
+		default:
+			return Result{Post: post}
+		}
+	}, func(_ int, res Result) {
+		report(res)
+	})
+}
+
+// Push pushes a single post to DEV and, on success, records the article's URL
+// in the post's front matter as devtoUrl. Failing to record the URL doesn't
+// fail the push; it is added to the post's Diagnostics instead.
+func (e *Executor) Push(ctx context.Context, post *PostPlan) (devto.Article, error) {
+	art, err := e.Client.UpdateArticle(ctx, post.DevtoID, post.Article)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("updating devto id %s: %w", logutil.Yel(strconv.Itoa(post.DevtoID)), err)
+	}
+
+	// After a successful update, add the devtoUrl to the front matter.
+	if err := addDevtoUrlToFrontMatter(post.Path, art.URL.String()); err != nil {
+		post.Diagnostics = append(post.Diagnostics, Diagnostic{
+			Level:   DiagnosticError,
+			Message: fmt.Sprintf("failed to update front matter with devtoUrl: %s", err),
+		})
+	}
+
+	return art, nil
+}
+
+// Create creates the DEV article for a post that has no devtoId yet. On
+// success, the article's ID and URL are recorded in the post's front matter as
+// devtoId and devtoUrl so that the next push updates the article instead of
+// creating a new one.
+func (e *Executor) Create(ctx context.Context, post *PostPlan) (devto.Article, error) {
+	art, err := e.Client.CreateArticle(ctx, post.Article)
+	if err != nil {
+		return devto.Article{}, fmt.Errorf("creating devto article: %w", err)
+	}
+	post.DevtoID = int(art.ID)
+
+	if err := addDevtoIdToFrontMatter(post.Path, post.DevtoID); err != nil {
+		return art, fmt.Errorf("devto article %s was created but the front matter could not be updated, please add 'devtoId: %d' manually: %w",
+			logutil.Yel(strconv.Itoa(post.DevtoID)),
+			post.DevtoID,
+			err,
+		)
+	}
+
+	if err := addDevtoUrlToFrontMatter(post.Path, art.URL.String()); err != nil {
+		post.Diagnostics = append(post.Diagnostics, Diagnostic{
+			Level:   DiagnosticError,
+			Message: fmt.Sprintf("failed to update front matter with devtoUrl: %s", err),
+		})
+	}
+
+	return art, nil
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/maelvls/hudevto/frontmatter"
+)
+
+// addDevtoUrlToFrontMatter adds or updates the devtoUrl field in the front
+// matter of a Markdown file. The YAML, TOML and JSON front matter formats are
+// supported.
+func addDevtoUrlToFrontMatter(filePath string, url string) error {
+	return frontmatter.SetFields(filePath, frontmatter.Field{Key: "devtoUrl", Value: url})
+}
+
+// addDevtoIdToFrontMatter adds or updates the devtoId field in the front
+// matter of a Markdown file. It is used after a DEV article was created for
+// the post.
+func addDevtoIdToFrontMatter(filePath string, devtoId int) error {
+	return frontmatter.SetFields(filePath, frontmatter.Field{Key: "devtoId", Value: devtoId})
+}
+
+// devtoFrontMatter returns the front matter written at the top of the body
+// pushed to DEV, including the --- lines. The strings are always double-quoted
+// so that titles and descriptions containing quotes, colons or line breaks
+// are still valid YAML, which DEV would otherwise reject with "could not find
+// expected ':'".
+func devtoFrontMatter(art Article, date time.Time) (string, error) {
+	fm := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, value *yaml.Node) {
+		fm.Content = append(fm.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+	str := func(s string) *yaml.Node {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Style: yaml.DoubleQuotedStyle, Value: s}
+	}
+
+	add("title", str(art.Title))
+	add("description", str(art.Description))
+	add("published", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(art.Published)})
+	add("tags", str(strings.Join(art.Tags, ", ")))
+	add("date", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: date.UTC().Format("20060102T15:04Z")})
+	add("series", str(art.Series))
+	add("canonical_url", str(art.CanonicalURL))
+	add("cover_image", str(art.MainImage))
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	enc := yaml.NewEncoder(&b)
+	if err := enc.Encode(fm); err != nil {
+		return "", fmt.Errorf("while encoding the DEV front matter: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return "", fmt.Errorf("while encoding the DEV front matter: %w", err)
+	}
+	b.WriteString("---\n")
+	return b.String(), nil
+}
@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/maelvls/undent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func Test_addDevtoIdToFrontMatter(t *testing.T) {
+	t.Run("adds the field when missing", func(t *testing.T) {
+		path := withFile(t, undent.Undent(`
+			---
+			title: "Foo"
+			---
+			Body.
+		`))
+
+		err := addDevtoIdToFrontMatter(path, 42)
+		require.NoError(t, err)
+
+		assert.Equal(t, undent.Undent(`
+			---
+			title: "Foo"
+			devtoId: 42
+			---
+			Body.
+		`), readFile(t, path))
+	})
+
+	t.Run("replaces the existing field", func(t *testing.T) {
+		path := withFile(t, undent.Undent(`
+			---
+			title: "Foo"
+			devtoUrl: https://dev.to/old
+			---
+			Body.
+		`))
+
+		err := addDevtoUrlToFrontMatter(path, "https://dev.to/new")
+		require.NoError(t, err)
+
+		assert.Equal(t, undent.Undent(`
+			---
+			title: "Foo"
+			devtoUrl: https://dev.to/new
+			---
+			Body.
+		`), readFile(t, path))
+	})
+
+	t.Run("toml front matter", func(t *testing.T) {
+		path := withFile(t, undent.Undent(`
+			+++
+			title = "Foo"
+			+++
+			Body.
+		`))
+
+		err := addDevtoIdToFrontMatter(path, 42)
+		require.NoError(t, err)
+
+		assert.Equal(t, undent.Undent(`
+			+++
+			title = "Foo"
+			devtoId = 42
+			+++
+			Body.
+		`), readFile(t, path))
+	})
+
+	t.Run("no front matter", func(t *testing.T) {
+		path := withFile(t, "Body.\n")
+
+		err := addDevtoIdToFrontMatter(path, 42)
+		require.Error(t, err)
+	})
+}
+
+func withFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "index.md")
+	err := os.WriteFile(path, []byte(content), 0644)
+	require.NoError(t, err)
+	return path
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func Test_devtoFrontMatter(t *testing.T) {
+	date := time.Date(2024, 1, 2, 15, 4, 0, 0, time.UTC)
+
+	t.Run("simple article", func(t *testing.T) {
+		got, err := devtoFrontMatter(Article{
+			Title:        "Foo",
+			Description:  "Bar",
+			Published:    true,
+			Tags:         []string{"go", "hugo"},
+			CanonicalURL: "https://blog.example.com/foo/",
+		}, date)
+		require.NoError(t, err)
+		assert.Equal(t, "---\n"+
+			"title: \"Foo\"\n"+
+			"description: \"Bar\"\n"+
+			"published: true\n"+
+			"tags: \"go, hugo\"\n"+
+			"date: 20240102T15:04Z\n"+
+			"series: \"\"\n"+
+			"canonical_url: \"https://blog.example.com/foo/\"\n"+
+			"cover_image: \"\"\n"+
+			"---\n", got)
+	})
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"double quotes", `The "best" way`},
+		{"single quotes", `Don't do it`},
+		{"colon", "Hugo: the good parts"},
+		{"quotes and colon", `Why "hudevto": a story`},
+		{"colon and space at the end", "Why? Because: "},
+		{"hash", "C# # not a comment"},
+		{"backslash", `C:\Users\foo`},
+		{"leading dash", "- not a list"},
+		{"leading special chars", "@foo & *bar | >baz"},
+		{"unicode", "Déjà vu, 日本語, Ελληνικά"},
+		{"emoji", "Shipping 🚀 with Go 🐹"},
+		{"multi-line", "First line.\nSecond line.\n\nThird paragraph."},
+		{"trailing newline", "Foo.\n"},
+		{"tab", "a\tb"},
+		{"looks like a bool", "true"},
+		{"looks like a number", "1.0"},
+		{"looks like null", "null"},
+		{"long", strings.Repeat("We often talk about avoiding unnecessary comments. ", 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := Article{
+				Title:        tt.value,
+				Description:  tt.value,
+				Tags:         []string{"go"},
+				Series:       tt.value,
+				CanonicalURL: "https://blog.example.com/foo/",
+				MainImage:    "https://blog.example.com/foo/cover.png",
+			}
+			got, err := devtoFrontMatter(want, date)
+			require.NoError(t, err)
+
+			fm, body := splitFrontMatter(got + "Body.\n")
+			assert.Equal(t, "Body.\n", body)
+			assert.Equal(t, strings.Count(got, "\n"), 10, "each field is expected to be on its own line")
+
+			var parsed struct {
+				Title        string `yaml:"title"`
+				Description  string `yaml:"description"`
+				Published    bool   `yaml:"published"`
+				Tags         string `yaml:"tags"`
+				Date         string `yaml:"date"`
+				Series       string `yaml:"series"`
+				CanonicalURL string `yaml:"canonical_url"`
+				CoverImage   string `yaml:"cover_image"`
+			}
+			require.NoError(t, yaml.Unmarshal([]byte(fm), &parsed))
+			assert.Equal(t, want.Title, parsed.Title)
+			assert.Equal(t, want.Description, parsed.Description)
+			assert.Equal(t, false, parsed.Published)
+			assert.Equal(t, "go", parsed.Tags)
+			assert.Equal(t, "20240102T15:04Z", parsed.Date)
+			assert.Equal(t, want.Series, parsed.Series)
+			assert.Equal(t, want.CanonicalURL, parsed.CanonicalURL)
+			assert.Equal(t, want.MainImage, parsed.CoverImage)
+			assert.Equal(t, want.Series, frontMatterValue(fm, "series"))
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package sync
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gohugoio/hugo/config"
+	"github.com/gohugoio/hugo/config/allconfig"
+	"github.com/gohugoio/hugo/deps"
+	"github.com/gohugoio/hugo/hugofs"
+	"github.com/gohugoio/hugo/hugolib"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// LoadOptions tells LoadSites where to find the Hugo config. The zero value
+// finds the config the same way the hugo command does.
+type LoadOptions struct {
+	// Config is a comma-separated list of config files relative to the root
+	// directory, like Hugo's --config flag. When empty, the first of
+	// hugo.toml, hugo.yaml, hugo.json, config.toml, config.yaml and
+	// config.json that exists is used.
+	Config string
+
+	// ConfigDir is the directory containing the _default/ and per-environment
+	// config directories, relative to the root directory. Defaults to
+	// "config".
+	ConfigDir string
+
+	// Environment picks the config directory that is merged on top of
+	// _default/, e.g., config/production/. Defaults to $HUGO_ENVIRONMENT, and
+	// then to "production" like the hugo command.
+	Environment string
+}
+
+// LoadSites loads the Hugo project found in rootDirOrDot and processes its
+// content without rendering anything. The rootDirOrDot cannot be left empty; if
+// you want to use the current working directory, use ".".
+func LoadSites(rootDirOrDot string, opts LoadOptions) (*hugolib.HugoSites, error) {
+	if rootDirOrDot == "" {
+		panic("programmer mistake: LoadSites: rootDirOrDot cannot be empty")
+	}
+	rootDir := filepath.Clean(rootDirOrDot)
+	if rootDir == "." {
+		var err error
+		rootDir, err = os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("while getting current working directory: %w", err)
+		}
+	}
+	logutil.Debugf("using rootDir='%s', rootDirOrDot='%s'", logutil.Gray(rootDir), logutil.Gray(rootDirOrDot))
+
+	if opts.ConfigDir == "" {
+		opts.ConfigDir = "config"
+	}
+	if opts.Environment == "" {
+		opts.Environment = os.Getenv("HUGO_ENVIRONMENT")
+	}
+	if opts.Environment == "" {
+		opts.Environment = "production"
+	}
+
+	// The paths are relative to the root directory since the file system
+	// given to Hugo is rooted there.
+	var configFiles []string
+	for _, name := range strings.Split(opts.Config, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if filepath.IsAbs(name) {
+			rel, err := filepath.Rel(rootDir, name)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				return nil, fmt.Errorf("config file %s must be within the root directory %s", name, rootDir)
+			}
+			name = rel
+		}
+		configFiles = append(configFiles, name)
+	}
+
+	fs := hugofs.NewBasePathFs(hugofs.Os, rootDir)
+	configs, err := allconfig.LoadConfig(allconfig.ConfigSourceDescriptor{
+		Fs:          fs,
+		Filename:    strings.Join(configFiles, ","),
+		ConfigDir:   opts.ConfigDir,
+		Environment: opts.Environment,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while loading config: %w", err)
+	}
+	if len(configs.LoadingInfo.ConfigFiles) == 0 && len(configFiles) > 0 {
+		return nil, fmt.Errorf("none of the config files %s exist in %s", strings.Join(configFiles, ", "), rootDir)
+	}
+	if len(configs.LoadingInfo.ConfigFiles) == 0 {
+		return nil, fmt.Errorf("no Hugo config found in %s, tried %s, %s and %s",
+			rootDir,
+			"hugo.{toml,yaml,json}",
+			"config.{toml,yaml,json}",
+			filepath.Join(opts.ConfigDir, "_default", "*"),
+		)
+	}
+	logutil.Debugf("using the Hugo config files %s with environment %s", logutil.Gray(strings.Join(configs.LoadingInfo.ConfigFiles, ", ")), logutil.Gray(opts.Environment))
+
+	configProvider := config.New()
+	configProvider.Set("workingDir", rootDir)
+	configProvider.Set("publishDir", "unused")
+	configProvider.Set("themesDir", filepath.Join(rootDir, "themes"))
+
+	sites, err := hugolib.NewHugoSites(deps.DepsCfg{
+		Fs:      hugofs.NewFromSourceAndDestination(fs, fs, configProvider),
+		Configs: configs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("while creating sites: %w", err)
+	}
+
+	err = sites.Build(hugolib.BuildCfg{SkipRender: true})
+	if err != nil {
+		return nil, fmt.Errorf("while processing content: %w", err)
+	}
+
+	return sites, nil
+}
+
+// This is meant to turn the URL path returned by Hugo's page.Path() into a file
+// path to the Markdown file of the page. For example, if the page.Path() is
+//
+//	article
+//
+// then the file path might either be:
+//
+//	(1) A markdown file:                  content/article.md
+//	(2) A folder containing index.md:    content/article/index.md
+//
+// This func returns the file path out of the URL path. The rootDir cannot be
+// empty; to use the current dir, usr ".".
+func pagePathToFilePath(rootDir, hugoPath string) (string, error) {
+	// Case (1): the hugoPath is a file, so we return the file path.
+	pathIsMD := filepath.Join(rootDir, "content", hugoPath+".md")
+	_, err := os.Stat(pathIsMD)
+	if err == nil {
+		return pathIsMD, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("while checking if %s exists: %w", pathIsMD, err)
+	}
+
+	// At this point, we know that it's not (2), so it must be (1).
+	pathIsIndexMD := filepath.Join(rootDir, "content", hugoPath, "index.md")
+	_, err = os.Stat(pathIsIndexMD)
+	if err == nil {
+		return pathIsIndexMD, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("while checking if %s exists: %w", pathIsIndexMD, err)
+	}
+
+	return "", fmt.Errorf("wasn't able to find the source file for the URL path %s, tried:\n"+
+		"  - as a Markdown file (%s)\n"+
+		"  - as an index file (%s)",
+		hugoPath,
+		logutil.Gray(pathIsMD),
+		logutil.Gray(pathIsIndexMD),
+	)
+}
+
+// intParam returns the value of an integer front matter field. Hugo decodes
+// the integers as int from YAML, as int64 from TOML and as float64 from JSON,
+// so all three are accepted. The returned bool is false when the value isn't
+// an integer, e.g., 1.5 or "42".
+func intParam(raw any) (int, bool) {
+	switch v := raw.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case uint64:
+		return int(v), true
+	case float64:
+		// Past 2^53, the floats can't tell the integers apart.
+		if v != math.Trunc(v) || math.Abs(v) > 1<<53 {
+			return 0, false
+		}
+		return int(v), true
+	}
+	return 0, false
+}
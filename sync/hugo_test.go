@@ -0,0 +1,167 @@
+package sync
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maelvls/undent"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPagePathToFilePath(t *testing.T) {
+	t.Run("file exists", func(t *testing.T) {
+		root := t.TempDir()
+		withContentDir(t, root, "content/article.md")
+
+		p, err := pagePathToFilePath(root, "article")
+
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(root, "content/article.md"), p)
+	})
+
+	t.Run("directory with index.md exists", func(t *testing.T) {
+		root := t.TempDir()
+		withContentDir(t, root, "content/my-article/index.md")
+
+		p, err := pagePathToFilePath(root, "my-article")
+
+		require.NoError(t, err)
+		require.Equal(t, filepath.Join(root, "content/my-article/index.md"), p)
+	})
+
+	t.Run("neither exists", func(t *testing.T) {
+		root := t.TempDir()
+
+		p, err := pagePathToFilePath(root, "notfound")
+		require.Error(t, err)
+		assert.Empty(t, p)
+		assert.Equal(t, undent.Undent(`
+			wasn't able to find the source file for the URL path notfound, tried:
+			  - as a Markdown file (<root>/content/notfound.md)
+			  - as an index file (<root>/content/notfound/index.md)`),
+			rmAnsicodes(strings.ReplaceAll(err.Error(), root, "<root>")))
+
+	})
+
+	t.Run("permission error", func(t *testing.T) {
+		root := t.TempDir()
+
+		badDir := filepath.Join(root, "content")
+		err := os.MkdirAll(badDir, 0000)
+		require.NoError(t, err)
+
+		_, err = pagePathToFilePath(root, "bad-article")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "content/bad-article.md: permission denied")
+	})
+}
+
+// path can be:
+// - content/article.md
+// - content/other/index.md
+func withContentDir(t *testing.T, tmpDir, mdPath string) {
+	dir := filepath.Join(tmpDir, path.Dir(mdPath))
+	err := os.MkdirAll(dir, 0755)
+	require.NoError(t, err, "failed to create content directory")
+
+	filePath := filepath.Join(dir, path.Base(mdPath))
+	err = os.WriteFile(filePath, []byte("test content"), 0644)
+	require.NoError(t, err, "failed to create content file")
+}
+
+func rmAnsicodes(s string) string {
+	// Remove ANSI escape codes from the string.
+	// This is a simple implementation that removes all escape sequences.
+	return strings.NewReplacer(
+		"\x1b[0;90m", "",
+		"\x1b[0m", "",
+	).Replace(s)
+}
+
+func TestLoadSites(t *testing.T) {
+	withFiles := func(t *testing.T, files map[string]string) string {
+		root := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(root, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+		}
+		return root
+	}
+	post := "---\ntitle: Post\n---\nBody.\n"
+
+	t.Run("hugo.toml", func(t *testing.T) {
+		root := withFiles(t, map[string]string{
+			"hugo.toml":       `baseURL = "https://from-hugo-toml.example/"`,
+			"content/post.md": post,
+		})
+
+		sites, err := LoadSites(root, LoadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://from-hugo-toml.example/post/", sites.GetContentPage("/content/post.md").Permalink())
+	})
+
+	t.Run("config directory with environment", func(t *testing.T) {
+		root := withFiles(t, map[string]string{
+			"config/_default/hugo.yaml":   "baseURL: https://default.example/\n",
+			"config/production/hugo.yaml": "baseURL: https://production.example/\n",
+			"config/staging/hugo.yaml":    "baseURL: https://staging.example/\n",
+			"content/post.md":             post,
+		})
+
+		sites, err := LoadSites(root, LoadOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, "https://production.example/post/", sites.GetContentPage("/content/post.md").Permalink())
+
+		sites, err = LoadSites(root, LoadOptions{Environment: "staging"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://staging.example/post/", sites.GetContentPage("/content/post.md").Permalink())
+	})
+
+	t.Run("explicit config file", func(t *testing.T) {
+		root := withFiles(t, map[string]string{
+			"config.yaml":     "baseURL: https://from-config-yaml.example/\n",
+			"other.toml":      `baseURL = "https://from-other-toml.example/"`,
+			"content/post.md": post,
+		})
+
+		sites, err := LoadSites(root, LoadOptions{Config: "other.toml"})
+		require.NoError(t, err)
+		assert.Equal(t, "https://from-other-toml.example/post/", sites.GetContentPage("/content/post.md").Permalink())
+	})
+
+	t.Run("no config", func(t *testing.T) {
+		root := withFiles(t, map[string]string{
+			"content/post.md": post,
+		})
+
+		_, err := LoadSites(root, LoadOptions{})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no Hugo config found")
+	})
+}
+
+func Test_intParam(t *testing.T) {
+	tests := []struct {
+		given  any
+		expect int
+		ok     bool
+	}{
+		{given: 42, expect: 42, ok: true},        // YAML
+		{given: int64(42), expect: 42, ok: true}, // TOML
+		{given: 42.0, expect: 42, ok: true},      // JSON
+		{given: 1.5},
+		{given: "42"},
+		{given: true},
+		{given: nil},
+	}
+	for _, tt := range tests {
+		got, ok := intParam(tt.given)
+		assert.Equal(t, tt.ok, ok, "%#v", tt.given)
+		assert.Equal(t, tt.expect, got, "%#v", tt.given)
+	}
+}
@@ -0,0 +1,168 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// How the images of the posts are checked, see Planner.CheckImages.
+const (
+	// The images that are on the blog are looked up in the page bundles and
+	// in the static directories, without any request.
+	ImageCheckOffline = "offline"
+	// In addition to the offline check, a HEAD request is made for each
+	// image, including the ones that aren't on the blog. This catches the
+	// images that exist locally but aren't deployed yet.
+	ImageCheckOnline = "online"
+)
+
+// MissingImage is an image of a post that DEV won't be able to show.
+type MissingImage struct {
+	// Line is the line of the image in the Markdown file, starting at 1.
+	Line int
+
+	// Ref is the image as written in the post, e.g., cover.png.
+	Ref string
+
+	// URL is where DEV will look for the image, e.g.,
+	// https://maelvls.dev/you-should-write-comments/cover.png.
+	URL string
+
+	// Reason tells why the image is missing, e.g., "404 Not Found".
+	Reason string
+}
+
+// imageChecker finds the images of the posts that don't exist. It is shared
+// by all the posts of a plan so that an image used by several posts is only
+// requested once.
+type imageChecker struct {
+	mode string
+	http *http.Client
+
+	mu    gosync.Mutex
+	heads map[string]string // The reason by URL, empty when the image exists.
+}
+
+func newImageChecker(mode string, client *http.Client) (*imageChecker, error) {
+	switch mode {
+	case ImageCheckOffline, ImageCheckOnline:
+	default:
+		return nil, fmt.Errorf("unknown image check %q, expected one of offline or online", mode)
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &imageChecker{
+		mode:  mode,
+		http:  client,
+		heads: make(map[string]string),
+	}, nil
+}
+
+// check returns the images of the post that can't be found. The images are
+// read from the post as written, so that the lines are the ones of the
+// Markdown file, and are resolved against the post's URL like the
+// "absolute-urls" transformer does. The images given with a shortcode aren't
+// checked.
+func (c *imageChecker) check(ctx context.Context, sites *hugolib.HugoSites, pg page.Page, pathToMD string) []MissingImage {
+	base, err := url.Parse(pg.Permalink())
+	if err != nil {
+		return nil
+	}
+	body := pg.RawContent()
+	offset := bodyLineOffset(pathToMD, body)
+
+	var missing []MissingImage
+	for _, ref := range findURLs([]byte(body)) {
+		if !ref.image || ref.url == "" || strings.HasPrefix(ref.url, "#") || strings.Contains(ref.url, "{{") {
+			continue
+		}
+		line := offset + lineAt(body, ref.start)
+		u, err := url.Parse(ref.url)
+		if err != nil {
+			missing = append(missing, MissingImage{Line: line, Ref: ref.url, URL: ref.url, Reason: "invalid URL"})
+			continue
+		}
+		u = base.ResolveReference(u)
+		if u.Scheme != "http" && u.Scheme != "https" {
+			// E.g., data:image/png;base64,...
+			continue
+		}
+
+		reason := ""
+		if u.Host == base.Host {
+			if _, found := siteFile(sites, pg, u); !found {
+				reason = "not found in the page bundles or in the static directories"
+			}
+		}
+		if reason == "" && c.mode == ImageCheckOnline {
+			reason = c.head(ctx, u.String())
+		}
+		if reason != "" {
+			missing = append(missing, MissingImage{Line: line, Ref: ref.url, URL: u.String(), Reason: reason})
+		}
+	}
+	return missing
+}
+
+// head returns why the image can't be fetched, or an empty string when it
+// can. Some servers don't support HEAD, in which case a GET is made instead.
+func (c *imageChecker) head(ctx context.Context, link string) string {
+	c.mu.Lock()
+	reason, done := c.heads[link]
+	c.mu.Unlock()
+	if done {
+		return reason
+	}
+
+	for _, method := range []string{"HEAD", "GET"} {
+		req, err := http.NewRequestWithContext(ctx, method, link, nil)
+		if err != nil {
+			reason = err.Error()
+			break
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			reason = err.Error()
+			break
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusMethodNotAllowed && method == "HEAD" {
+			continue
+		}
+		reason = ""
+		if resp.StatusCode >= 400 {
+			reason = resp.Status
+		}
+		break
+	}
+
+	c.mu.Lock()
+	c.heads[link] = reason
+	c.mu.Unlock()
+	return reason
+}
+
+// bodyLineOffset returns the number of lines that come before the body in the
+// Markdown file, i.e., the lines of the front matter. It returns 0 when the
+// file can't be read.
+func bodyLineOffset(pathToMD, body string) int {
+	file, err := os.ReadFile(pathToMD)
+	if err != nil {
+		return 0
+	}
+	i := strings.Index(string(file), body)
+	if i < 0 {
+		return 0
+	}
+	return strings.Count(string(file[:i]), "\n")
+}
@@ -0,0 +1,85 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_imageChecker(t *testing.T) {
+	// Serves both the images of the blog and the ones hosted elsewhere.
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/gopher.png" && r.URL.Path != "/posts/images/setup.png" {
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(images.Close)
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "blog.example.com" {
+			req.URL.Scheme, req.URL.Host = "http", strings.TrimPrefix(images.URL, "http://")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})}
+
+	root, sites := loadTestSite(t, map[string]string{
+		"content/posts/images/index.md": testPost("" +
+			"![Setup](setup.png)\n" +
+			"![Gopher](" + images.URL + "/gopher.png) and ![Gone](" + images.URL + "/gone.png)\n" +
+			"\n" +
+			"<img src=\"/images/logo.png\"> and [a link](missing.html)\n" +
+			"\n" +
+			"```\n" +
+			"![In a code block](missing.png)\n" +
+			"```\n"),
+		"content/posts/images/setup.png": "setup",
+	})
+	tc := newTestContext(t, root, sites, "content/posts/images/index.md")
+
+	logo := MissingImage{
+		Line:   10,
+		Ref:    "/images/logo.png",
+		URL:    "https://blog.example.com/images/logo.png",
+		Reason: "not found in the page bundles or in the static directories",
+	}
+	tests := []struct {
+		check  string
+		expect []MissingImage
+	}{
+		{
+			check:  ImageCheckOffline,
+			expect: []MissingImage{logo},
+		},
+		{
+			check: ImageCheckOnline,
+			expect: []MissingImage{{
+				Line:   8,
+				Ref:    images.URL + "/gone.png",
+				URL:    images.URL + "/gone.png",
+				Reason: "404 Not Found",
+			}, logo},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.check, func(t *testing.T) {
+			c, err := newImageChecker(tt.check, client)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, c.check(context.Background(), sites, tc.Page, tc.Path))
+		})
+	}
+
+	t.Run("unknown check", func(t *testing.T) {
+		_, err := newImageChecker("foo", nil)
+		assert.EqualError(t, err, `unknown image check "foo", expected one of offline or online`)
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
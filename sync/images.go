@@ -0,0 +1,323 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	gosync "sync"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/spf13/afero"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// Where the images are uploaded, see ImageOptions.
+//
+// DEV has no API for uploading images: its editor uploads them with the
+// session cookie of the user, which hudevto doesn't have. The images are
+// thus hosted elsewhere.
+const (
+	// The images are uploaded to an S3-compatible bucket.
+	ImageBackendS3 = "s3"
+	// The images are copied to a local directory, e.g., a directory served
+	// by a CDN or, in tests, a temporary directory.
+	ImageBackendDir = "dir"
+)
+
+// DefaultImageCache is where the URLs of the uploaded images are kept,
+// relative to the root directory of the Hugo project.
+const DefaultImageCache = ".hudevto-images.json"
+
+// ImageOptions are the params of the "upload-images" transformer.
+type ImageOptions struct {
+	// Backend is one of the ImageBackend* values. Defaults to
+	// ImageBackendS3.
+	Backend string `yaml:"backend"`
+
+	// Cache is the file, relative to the root directory of the Hugo project,
+	// where the URLs of the uploaded images are kept so that an image is only
+	// uploaded once. Defaults to DefaultImageCache.
+	Cache string `yaml:"cache"`
+
+	S3  S3Options  `yaml:"s3"`
+	Dir DirOptions `yaml:"dir"`
+}
+
+// DirOptions configures ImageBackendDir.
+type DirOptions struct {
+	// Path is the directory where the images are copied, relative to the root
+	// directory of the Hugo project.
+	Path string `yaml:"path"`
+
+	// URL is the URL at which the directory is served, e.g.,
+	// https://cdn.example.com/images/.
+	URL string `yaml:"url"`
+}
+
+// imageUploader uploads an image and returns its URL. The name is unique to
+// the content of the image.
+type imageUploader interface {
+	upload(ctx context.Context, name string, content []byte) (string, error)
+}
+
+// imageHost is the "upload-images" transformer. The local images that the
+// posts use, i.e., the files of the page bundles and of the static
+// directories, are uploaded so that DEV doesn't hotlink the images of the
+// blog. Since the transformer runs for every post of a plan, which may be
+// planned concurrently, the cache and the uploader are guarded by mu.
+type imageHost struct {
+	opts ImageOptions
+
+	// The backend is created on first use so that the credentials are only
+	// needed when an image is uploaded.
+	mu       gosync.Mutex
+	uploader imageUploader
+	cache    map[string]string // By backendID and hash of the image.
+	loaded   bool
+}
+
+func newImageHost(opts ImageOptions) (*imageHost, error) {
+	if opts.Cache == "" {
+		opts.Cache = DefaultImageCache
+	}
+	switch opts.Backend {
+	case "":
+		opts.Backend = ImageBackendS3
+		fallthrough
+	case ImageBackendS3:
+		if err := opts.S3.validate(); err != nil {
+			return nil, fmt.Errorf("s3: %w", err)
+		}
+	case ImageBackendDir:
+		if opts.Dir.Path == "" || opts.Dir.URL == "" {
+			return nil, fmt.Errorf("dir: both path and url must be set")
+		}
+	default:
+		return nil, fmt.Errorf("unknown backend %q, expected one of s3 or dir", opts.Backend)
+	}
+	return &imageHost{opts: opts}, nil
+}
+
+// backendID identifies where the images go so that changing the backend,
+// e.g., to another bucket, uploads the images again.
+func (h *imageHost) backendID() string {
+	if h.opts.Backend == ImageBackendDir {
+		return "dir:" + h.opts.Dir.URL
+	}
+	return "s3:" + strings.TrimSuffix(h.opts.S3.Endpoint, "/") + "/" + h.opts.S3.Bucket + "/" + h.opts.S3.Prefix
+}
+
+func (h *imageHost) transform(tc TransformContext, body string) (string, error) {
+	base, err := url.Parse(tc.Page.Permalink())
+	if err != nil {
+		return "", fmt.Errorf("while parsing the permalink of the post: %w", err)
+	}
+
+	var errs []error
+	body = rewriteURLs(body, func(ref string) (string, bool) {
+		uploaded, err := h.imageURL(tc, base, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", ref, err))
+			return "", false
+		}
+		return uploaded, uploaded != ""
+	})
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return body, nil
+}
+
+// imageURL returns the URL of the uploaded image, or an empty string when
+// the ref isn't a local image or when the image can only be uploaded when
+// pushing.
+func (h *imageHost) imageURL(tc TransformContext, base *url.URL, ref string) (string, error) {
+	name, content, found := localImage(tc, base, ref)
+	if !found {
+		return "", nil
+	}
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+
+	h.mu.Lock()
+	if err := h.loadCache(tc); err != nil {
+		h.mu.Unlock()
+		return "", err
+	}
+	key := h.backendID() + ":" + hash
+	if uploaded, ok := h.cache[key]; ok {
+		h.mu.Unlock()
+		return uploaded, nil
+	}
+	if !tc.Push {
+		h.mu.Unlock()
+		logutil.Debugf("%s: %s will be uploaded when pushing", logutil.Gray(tc.Path), ref)
+		if tc.pendingImages != nil {
+			*tc.pendingImages = append(*tc.pendingImages, ref)
+		}
+		return "", nil
+	}
+	if h.uploader == nil {
+		var err error
+		h.uploader, err = h.newUploader(tc)
+		if err != nil {
+			h.mu.Unlock()
+			return "", err
+		}
+	}
+	uploader := h.uploader
+	h.mu.Unlock()
+
+	// The lock isn't held during the upload so that the other posts aren't
+	// blocked by a slow upload. The same image may thus be uploaded twice
+	// when two posts use it, in which case the first URL is kept.
+	uploaded, err := uploader.upload(tc.ctx(), hash[:16]+"-"+name, content)
+	if err != nil {
+		return "", fmt.Errorf("while uploading: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if cached, ok := h.cache[key]; ok {
+		return cached, nil
+	}
+	tc.Infof("uploaded %s to %s", ref, uploaded)
+	h.cache[key] = uploaded
+	if err := h.saveCache(tc); err != nil {
+		return "", err
+	}
+	return uploaded, nil
+}
+
+func (h *imageHost) newUploader(tc TransformContext) (imageUploader, error) {
+	if h.opts.Backend == ImageBackendDir {
+		return dirUploader{dir: filepath.Join(tc.RootDir, h.opts.Dir.Path), url: h.opts.Dir.URL}, nil
+	}
+	return newS3Uploader(h.opts.S3)
+}
+
+// localImage finds the file of the image that ref points to, either in a
+// page bundle or in the static directories. The ref can be relative to the
+// post, e.g., cover.png or ../other-post/diagram.svg, or absolute, e.g.,
+// /images/logo.png or https://maelvls.dev/images/logo.png.
+func localImage(tc TransformContext, base *url.URL, ref string) (name string, content []byte, found bool) {
+	u, err := url.Parse(ref)
+	if err != nil || strings.HasPrefix(ref, "#") || strings.Contains(ref, "{{") {
+		return "", nil, false
+	}
+	u = base.ResolveReference(u)
+	if u.Host != base.Host || tc.Sites == nil || !isImagePath(u.Path) {
+		return "", nil, false
+	}
+	content, found = siteFile(tc.Sites, tc.Page, u)
+	if !found {
+		logutil.Debugf("%s: %s isn't a local image, leaving it as is", logutil.Gray(tc.Path), ref)
+		return "", nil, false
+	}
+	return path.Base(u.Path), content, true
+}
+
+// siteFile reads the file that the URL points to, which must be on the site,
+// from the page bundles or from the static directories of the page's
+// language.
+func siteFile(sites *hugolib.HugoSites, pg page.Page, u *url.URL) ([]byte, bool) {
+	// The paths within the site, e.g., posts/foo/cover.png, are relative to
+	// the baseURL, which may have a path.
+	sitePrefix := ""
+	if siteURL, err := url.Parse(pg.Site().BaseURL()); err == nil {
+		sitePrefix = strings.TrimSuffix(siteURL.Path, "/")
+	}
+	sitePath := func(p string) string {
+		return strings.TrimPrefix(strings.TrimPrefix(p, sitePrefix), "/")
+	}
+	filePath := sitePath(u.Path)
+
+	// The page bundles are looked up first, starting with the deepest one.
+	type bundle struct{ prefix, dir string }
+	var bundles []bundle
+	for _, bundlePage := range sites.Pages() {
+		f := bundlePage.File()
+		if f == nil || (f.BaseFileName() != "index" && f.BaseFileName() != "_index") {
+			continue
+		}
+		prefix := sitePath(bundlePage.RelPermalink())
+		if strings.HasPrefix(filePath, prefix) {
+			bundles = append(bundles, bundle{prefix: prefix, dir: f.Dir()})
+		}
+	}
+	sort.Slice(bundles, func(i, j int) bool { return len(bundles[i].prefix) > len(bundles[j].prefix) })
+	for _, b := range bundles {
+		content, err := afero.ReadFile(sites.BaseFs.Content.Fs, path.Join(b.dir, strings.TrimPrefix(filePath, b.prefix)))
+		if err == nil {
+			return content, true
+		}
+	}
+
+	content, err := afero.ReadFile(sites.BaseFs.StaticFs(pg.Lang()), filePath)
+	if err != nil {
+		return nil, false
+	}
+	return content, true
+}
+
+func (h *imageHost) cachePath(tc TransformContext) string {
+	return filepath.Join(tc.RootDir, h.opts.Cache)
+}
+
+// Must be called with mu held.
+func (h *imageHost) loadCache(tc TransformContext) error {
+	if h.loaded {
+		return nil
+	}
+	h.cache = make(map[string]string)
+	bytes, err := os.ReadFile(h.cachePath(tc))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("while reading the image cache: %w", err)
+	default:
+		if err := json.Unmarshal(bytes, &h.cache); err != nil {
+			return fmt.Errorf("while parsing the image cache %s: %w", h.cachePath(tc), err)
+		}
+	}
+	h.loaded = true
+	return nil
+}
+
+// The cache is saved after each upload so that the images uploaded before a
+// failure aren't uploaded again. Must be called with mu held.
+func (h *imageHost) saveCache(tc TransformContext) error {
+	bytes, err := json.MarshalIndent(h.cache, "", "  ")
+	if err != nil {
+		return fmt.Errorf("while writing the image cache: %w", err)
+	}
+	if err := os.WriteFile(h.cachePath(tc), append(bytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("while writing the image cache: %w", err)
+	}
+	return nil
+}
+
+type dirUploader struct {
+	dir, url string
+}
+
+func (u dirUploader) upload(_ context.Context, name string, content []byte) (string, error) {
+	if err := os.MkdirAll(u.dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(u.dir, name), content, 0644); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(u.url, "/") + "/" + url.PathEscape(name), nil
+}
@@ -0,0 +1,555 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// DefaultImportSection is where the imported posts are written, relative to
+// the content directory.
+const DefaultImportSection = "posts"
+
+// Importer writes DEV articles as Hugo posts. It undoes what the transformers
+// do when pushing: the Liquid tags become shortcodes, the DEV anchors become
+// Hugo anchors, the images are downloaded into the page bundle of the post,
+// and the absolute URLs of the blog become relative.
+type Importer struct {
+	// RootDir is the root directory of the Hugo project. It cannot be left
+	// empty; if you want to use the current working directory, use ".".
+	RootDir string
+
+	// Sites is the Hugo project loaded with LoadSites. It is used to skip
+	// the articles that are already mapped to a post and to find the images
+	// that are already on the blog.
+	Sites *hugolib.HugoSites
+
+	// Client is used to fetch the DEV articles.
+	Client *Client
+
+	// Section is the directory within the content directory where the posts
+	// are written, e.g., "blog". Defaults to DefaultImportSection.
+	Section string
+
+	// HTTP is used to download the images. Defaults to a client with a 30
+	// seconds timeout.
+	HTTP *http.Client
+}
+
+// ImportResult is the outcome of importing a single DEV article.
+type ImportResult struct {
+	ID    int
+	Title string
+
+	// Path is the Markdown file of the post, i.e., the index.md of its page
+	// bundle.
+	Path string
+
+	// Images lists the images downloaded into the page bundle.
+	Images []string
+
+	Err error
+}
+
+// Import imports the user's DEV articles with the given IDs, or, when no ID is
+// given, all the user's DEV articles that aren't mapped to a post yet. The articles
+// whose canonical URL is one of the posts, e.g., the ones created by DEV's RSS
+// importer, are left out too since they came from the blog; use 'hudevto
+// link' for them. The report func is called once per article.
+func (im *Importer) Import(ctx context.Context, ids []int, report func(ImportResult)) error {
+	if im.RootDir == "" {
+		panic("programmer mistake: Importer.Import: RootDir cannot be empty")
+	}
+
+	articles, err := im.Client.ListAllMyArticles(ctx)
+	if err != nil {
+		return fmt.Errorf("listing all the user's articles: %w", err)
+	}
+	byID := make(map[int]devto.ListedArticle)
+	for _, art := range articles {
+		byID[int(art.ID)] = art
+	}
+
+	mapped := make(map[int]string)
+	permalinks := make(map[string]bool)
+	for _, pg := range im.Sites.Pages() {
+		if pg.Kind() != "page" {
+			continue
+		}
+		permalinks[normalizeURL(pg.Permalink())] = true
+		if id, err := pg.Param("devtoId"); err == nil {
+			if id, ok := intParam(id); ok {
+				mapped[id] = pg.Path()
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		for _, art := range articles {
+			_, isMapped := mapped[int(art.ID)]
+			fromBlog := art.CanonicalURL != nil && art.CanonicalURL.URL != nil && permalinks[normalizeURL(art.CanonicalURL.String())]
+			if !isMapped && !fromBlog {
+				ids = append(ids, int(art.ID))
+			}
+		}
+		sort.Ints(ids)
+	}
+
+	for _, id := range ids {
+		if pagePath, ok := mapped[id]; ok {
+			report(ImportResult{ID: id, Err: fmt.Errorf("already mapped to the post %s", logutil.Gray(pagePath))})
+			continue
+		}
+		// The articles written by someone else could be fetched by ID, but
+		// the imported post couldn't be pushed since the article isn't the
+		// user's.
+		art, found := byID[id]
+		if !found {
+			report(ImportResult{ID: id, Err: fmt.Errorf("not one of your articles, see 'hudevto devto list'")})
+			continue
+		}
+		report(im.importArticle(ctx, art))
+	}
+	return nil
+}
+
+// importedFrontMatter is the front matter of an imported post.
+type importedFrontMatter struct {
+	Title          string     `yaml:"title"`
+	Description    string     `yaml:"description,omitempty"`
+	Date           *time.Time `yaml:"date,omitempty"`
+	Draft          bool       `yaml:"draft"`
+	Keywords       []string   `yaml:"keywords,omitempty"`
+	Series         string     `yaml:"series,omitempty"`
+	Images         []string   `yaml:"images,omitempty"`
+	DevtoID        int        `yaml:"devtoId"`
+	DevtoPublished bool       `yaml:"devtoPublished"`
+	DevtoURL       string     `yaml:"devtoUrl,omitempty"`
+}
+
+func (im *Importer) importArticle(ctx context.Context, art devto.ListedArticle) ImportResult {
+	res := ImportResult{ID: int(art.ID), Title: art.Title}
+	section := im.Section
+	if section == "" {
+		section = DefaultImportSection
+	}
+	dir := filepath.Join(im.RootDir, "content", filepath.FromSlash(section), slugify(art.Title, int(art.ID)))
+	res.Path = filepath.Join(dir, "index.md")
+	if _, err := os.Stat(res.Path); err == nil {
+		res.Err = fmt.Errorf("%s already exists", logutil.Gray(res.Path))
+		return res
+	}
+
+	// The articles pushed by hudevto, like the ones written with DEV's
+	// Markdown editor, start with a front matter that takes precedence over
+	// the fields of the article. Only the series isn't in the fields.
+	fm, body := splitFrontMatter(art.BodyMarkdown)
+
+	dl := &downloader{http: im.HTTP, files: make(map[string][]byte), names: make(map[string]string)}
+	if dl.http == nil {
+		dl.http = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	body = liquidToShortcodes(res.Path, body)
+	body = hugoAnchorIDs(body, im.Sites.Sites[0].SanitizeAnchorName)
+	body = im.relativeURLs(ctx, res.Path, body, dl)
+
+	post := importedFrontMatter{
+		Title:          art.Title,
+		Description:    art.Description,
+		Date:           art.PublishedAt,
+		Draft:          !art.Published,
+		Keywords:       art.TagList,
+		Series:         frontMatterValue(fm, "series"),
+		DevtoID:        int(art.ID),
+		DevtoPublished: art.Published,
+	}
+	if art.URL != nil && art.URL.URL != nil {
+		post.DevtoURL = art.URL.String()
+	}
+	if art.CoverImage != nil && art.CoverImage.URL != nil {
+		cover, err := dl.download(ctx, art.CoverImage.String(), "cover")
+		if err != nil {
+			logutil.Warnf("%s: while downloading the cover image, keeping its URL: %s", logutil.Gray(res.Path), err)
+			cover = art.CoverImage.String()
+		}
+		post.Images = []string{cover}
+	}
+
+	header, err := yaml.Marshal(post)
+	if err != nil {
+		res.Err = fmt.Errorf("while writing the front matter: %w", err)
+		return res
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		res.Err = err
+		return res
+	}
+	for _, name := range dl.order {
+		if err := os.WriteFile(filepath.Join(dir, name), dl.files[name], 0644); err != nil {
+			res.Err = err
+			return res
+		}
+		res.Images = append(res.Images, name)
+	}
+	content := "---\n" + string(header) + "---\n\n" + strings.TrimLeft(body, "\n")
+	if err := os.WriteFile(res.Path, []byte(content), 0644); err != nil {
+		res.Err = err
+		return res
+	}
+	return res
+}
+
+// slugify returns the name of the page bundle of the post, e.g.,
+// "you-should-write-comments" for "You should write comments!".
+func slugify(title string, id int) string {
+	slug := seriesKey(title)
+	if slug == "" {
+		return strconv.Itoa(id)
+	}
+	return slug
+}
+
+// A Liquid tag, e.g., {% youtube 30a0WrfaS2A %} or {% enddetails %}.
+var liquidTag = regexp.MustCompile(`{%-?\s*(end)?([a-zA-Z_]\w*)\s*(.*?)\s*-?%}`)
+
+// liquidToShortcodes converts the Liquid tags back to the Hugo shortcodes,
+// using the mappings of DefaultShortcodes, e.g.,
+//
+//	{% youtube 30a0WrfaS2A %}
+//
+// becomes:
+//
+//	{{< youtube id="30a0WrfaS2A" >}}
+//
+// The {% raw %} tags are removed since Hugo doesn't interpret the braces. The
+// tags listed in liquidLinks become a plain link, and the tags with no
+// equivalent shortcode, e.g., {% embed %}, are left as is. The code blocks and
+// code spans are left untouched.
+func liquidToShortcodes(pathToMD, body string) string {
+	shortcodes := liquidShortcodes()
+	skip := codeRanges([]byte(body))
+
+	var out strings.Builder
+	prev := 0
+	for _, m := range liquidTag.FindAllStringSubmatchIndex(body, -1) {
+		if inRanges(skip, m[0]) {
+			continue
+		}
+		out.WriteString(body[prev:m[0]])
+		prev = m[1]
+
+		closing, name, args := m[2] >= 0, body[m[4]:m[5]], body[m[6]:m[7]]
+		sc, ok := shortcodes[name]
+		link, isLink := liquidLinks[name]
+		switch {
+		case name == "raw":
+		case isLink && !closing:
+			logutil.Warnf("%s: line %d: no shortcode for the Liquid tag %s, replacing it with a link",
+				logutil.Gray(pathToMD), lineAt(body, m[0]), logutil.Yel(name),
+			)
+			out.WriteString("<" + link(strings.Trim(strings.TrimSpace(args), `"'`)) + ">")
+		case !ok:
+			logutil.Warnf("%s: line %d: no shortcode for the Liquid tag %s, leaving it as is",
+				logutil.Gray(pathToMD), lineAt(body, m[0]), logutil.Yel(name),
+			)
+			out.WriteString(body[m[0]:m[1]])
+		case closing:
+			out.WriteString("{{< /" + sc.name + " >}}")
+		default:
+			out.WriteString(sc.shortcode(args))
+		}
+	}
+	out.WriteString(body[prev:])
+	return out.String()
+}
+
+// liquidLinks are the Liquid tags that can't be converted back to a
+// shortcode. They are replaced with a link to what they embed. The tweet and x
+// shortcodes need the user on top of the ID given to {% twitter %}, and Hugo's
+// gist shortcode is deprecated.
+var liquidLinks = map[string]func(args string) string{
+	"twitter": func(id string) string { return "https://x.com/i/status/" + id },
+	"gist":    func(url string) string { return url },
+}
+
+// liquidShortcode is the shortcode that a Liquid tag comes from.
+type liquidShortcode struct {
+	name string
+
+	// keys are the names or positions of the params that the Liquid args
+	// are given to, see ShortcodeMapping.Args. When empty, all the args are
+	// given as positional params.
+	keys []string
+}
+
+// liquidShortcodes inverts DefaultShortcodes, except for the Liquid tags in
+// liquidLinks. When several shortcodes give the same Liquid tag, the first one
+// in alphabetical order is used.
+func liquidShortcodes() map[string]liquidShortcode {
+	mappings := DefaultShortcodes()
+	names := make([]string, 0, len(mappings))
+	for name := range mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	shortcodes := make(map[string]liquidShortcode)
+	for _, name := range names {
+		m := mappings[name]
+		if _, isLink := liquidLinks[m.Liquid]; m.Liquid == "" || isLink {
+			continue
+		}
+		if _, ok := shortcodes[m.Liquid]; ok {
+			continue
+		}
+		// The first alternative is the one that the shortcode expects, e.g.,
+		// "id" in "id|0" for youtube, whereas Hugo's instagram shortcode only
+		// accepts a positional param.
+		var keys []string
+		for _, arg := range m.Args {
+			key, _, _ := strings.Cut(arg, "|")
+			keys = append(keys, key)
+		}
+		shortcodes[m.Liquid] = liquidShortcode{name: name, keys: keys}
+	}
+	return shortcodes
+}
+
+func (sc liquidShortcode) shortcode(args string) string {
+	var params []shortcodeParam
+	switch {
+	case len(sc.keys) == 1:
+		// The only arg may contain spaces, e.g., {% details Click here %}.
+		p := shortcodeParam{Value: strings.Trim(args, `"'`)}
+		if _, err := strconv.Atoi(sc.keys[0]); err != nil {
+			p.Name = sc.keys[0]
+		}
+		params = []shortcodeParam{p}
+	default:
+		for i, arg := range strings.Fields(args) {
+			p := shortcodeParam{Value: strings.Trim(arg, `"'`)}
+			if i < len(sc.keys) {
+				if _, err := strconv.Atoi(sc.keys[i]); err != nil {
+					p.Name = sc.keys[i]
+				}
+			}
+			params = append(params, p)
+		}
+	}
+
+	out := "{{< " + sc.name
+	for _, p := range params {
+		out += " "
+		if p.Name != "" {
+			out += p.Name + "="
+		}
+		out += `"` + strings.ReplaceAll(p.Value, `"`, `\"`) + `"`
+	}
+	return out + " >}}"
+}
+
+// codeRanges returns where the code blocks and the code spans are.
+func codeRanges(src []byte) [][2]int {
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+	var ranges [][2]int
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			start, stop := linesRange(n.Lines())
+			ranges = append(ranges, [2]int{start, stop})
+			return ast.WalkSkipChildren, nil
+		case *ast.CodeSpan:
+			for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+				if t, ok := c.(*ast.Text); ok {
+					ranges = append(ranges, [2]int{t.Segment.Start, t.Segment.Stop})
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return ranges
+}
+
+// hugoAnchorIDs is the reverse of convertAnchorIDs: the links to the DEV
+// anchor IDs of the headings are changed to point to the Hugo anchor IDs.
+func hugoAnchorIDs(body string, sanitizeAnchorName func(string) string) string {
+	src := []byte(body)
+	devtoToHugo := make(map[string]string)
+	_ = ast.Walk(goldmark.DefaultParser().Parse(text.NewReader(src)), func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		h, ok := node.(*ast.Heading)
+		if !entering || !ok || h.Lines().Len() != 1 {
+			return ast.WalkContinue, nil
+		}
+		seg := h.Lines().At(0)
+		heading := string(seg.Value(src))
+		devtoToHugo[devtoAnchorID(heading)] = sanitizeAnchorName(heading)
+		return ast.WalkContinue, nil
+	})
+
+	return linkWithOnlyAnchor.ReplaceAllStringFunc(body, func(s string) string {
+		matches := linkWithOnlyAnchor.FindStringSubmatch(s)
+		anchor, found := devtoToHugo[matches[2]]
+		if !found {
+			return s
+		}
+		return strings.Replace(s, "#"+matches[2], "#"+anchor, 1)
+	})
+}
+
+// relativeURLs downloads the images of the post into its page bundle and
+// makes the URLs that point to the blog relative to the root of the site. The
+// images already on the blog aren't downloaded.
+func (im *Importer) relativeURLs(ctx context.Context, pathToMD, body string, dl *downloader) string {
+	site := im.Sites.Sites[0]
+	siteURL, err := url.Parse(site.BaseURL())
+	if err != nil {
+		return body
+	}
+	home := site.Home()
+
+	src := []byte(body)
+	var edits []urlEdit
+	for _, ref := range findURLs(src) {
+		u, err := url.Parse(ref.url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		onBlog := u.Host == siteURL.Host
+		relative := ""
+		switch {
+		case ref.image && onBlog && home != nil:
+			if _, found := siteFile(im.Sites, home, u); found {
+				relative = u.RequestURI()
+				break
+			}
+			fallthrough
+		case ref.image:
+			name, err := dl.download(ctx, ref.url, "")
+			if err != nil {
+				logutil.Warnf("%s: while downloading %s, keeping its URL: %s", logutil.Gray(pathToMD), ref.url, err)
+				continue
+			}
+			relative = name
+		case onBlog:
+			relative = u.RequestURI()
+			if u.Fragment != "" {
+				relative += "#" + u.EscapedFragment()
+			}
+		default:
+			continue
+		}
+		edits = append(edits, urlEdit{start: ref.start, stop: ref.stop, url: relative})
+	}
+	return applyURLEdits(src, edits)
+}
+
+// downloader downloads the images of a post. The same image is only downloaded
+// once, and two images with the same name are given different names.
+type downloader struct {
+	http  *http.Client
+	files map[string][]byte // By name.
+	names map[string]string // By URL.
+	order []string
+}
+
+// download returns the name of the downloaded image. The name is the one in
+// the URL unless base is given, e.g., "cover", in which case only the
+// extension is kept.
+func (dl *downloader) download(ctx context.Context, link, base string) (string, error) {
+	if name, ok := dl.names[link]; ok {
+		return name, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", link, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := dl.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	name := imageName(link, resp.Header.Get("Content-Type"))
+	if base != "" {
+		name = base + path.Ext(name)
+	}
+	unique := name
+	for i := 2; dl.files[unique] != nil; i++ {
+		unique = strconv.Itoa(i) + "-" + name
+	}
+	name = unique
+	dl.files[name] = content
+	dl.names[link] = name
+	dl.order = append(dl.order, name)
+	return name, nil
+}
+
+// imageName returns the file name of the image. DEV serves its images
+// through a resizing proxy whose URL ends with the URL of the original image,
+// e.g., https://media2.dev.to/dynamic/image/width=800/https%3A%2F%2Fdev-to-uploads.s3.amazonaws.com%2Fuploads%2Farticles%2Fabc.png,
+// so the path is unescaped to get the original name, abc.png.
+func imageName(link, contentType string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return "image"
+	}
+	p, err := url.PathUnescape(u.EscapedPath())
+	if err != nil {
+		p = u.Path
+	}
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(path.Base(p), "-"), "-.")
+	if name == "" {
+		name = "image"
+	}
+	if path.Ext(name) == "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		name += imageExtensions[mediaType]
+	}
+	return name
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// mime.ExtensionsByType gives the extensions in alphabetical order, e.g.,
+// .jfif for image/jpeg, hence this table.
+var imageExtensions = map[string]string{
+	"image/png":     ".png",
+	"image/jpeg":    ".jpg",
+	"image/gif":     ".gif",
+	"image/webp":    ".webp",
+	"image/svg+xml": ".svg",
+	"image/avif":    ".avif",
+}
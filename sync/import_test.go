@@ -0,0 +1,217 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maelvls/hudevto/devtotest"
+)
+
+func TestImporter_Import(t *testing.T) {
+	images := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Like DEV's resizing proxy, the URL of the original image is at the
+		// end of the path.
+		switch {
+		case r.URL.Path == "/cover":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = w.Write([]byte("cover"))
+		case strings.HasSuffix(r.URL.Path, "/uploads/diagram.png"):
+			_, _ = w.Write([]byte("diagram"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(images.Close)
+
+	srv := devtotest.NewServer(t)
+	srv.AddArticle(devtotest.Article{ID: 1001, Title: "Published post", Published: true})
+	srv.AddArticle(devtotest.Article{ID: 1002, Title: "From the RSS importer", CanonicalURL: "https://blog.example.com/posts/new/"})
+	written := srv.AddArticle(devtotest.Article{
+		ID:          2001,
+		Title:       "Written on DEV",
+		Description: "An article written with DEV's editor.",
+		Published:   true,
+		PublishedAt: time.Date(2023, 5, 6, 7, 8, 9, 0, time.UTC),
+		Tags:        []string{"go", "devto"},
+		CoverImage:  images.URL + "/cover",
+		BodyMarkdown: "---\n" +
+			"title: Written on DEV\n" +
+			"series: Go tips\n" +
+			"---\n" +
+			"\n" +
+			"## The `go.mod` file\n" +
+			"\n" +
+			"{% youtube 30a0WrfaS2A %}\n" +
+			"\n" +
+			"See [the go.mod section](#the-raw-gomod-endraw-file) and [the other post](https://blog.example.com/posts/published/#intro).\n" +
+			"\n" +
+			"![Diagram](" + images.URL + "/dynamic/image/width=800/" + strings.ReplaceAll(images.URL, ":", "%3A") + "%2Fuploads%2Fdiagram.png)\n" +
+			"![Setup](https://blog.example.com/posts/with-image/setup.png)\n" +
+			"![Gone](" + images.URL + "/gone.png)\n",
+	})
+	draft := srv.AddArticle(devtotest.Article{ID: 2002, Title: "Draft written on DEV", BodyMarkdown: "Not done yet.\n"})
+
+	root := copySite(t)
+	sites, err := LoadSites(root, LoadOptions{})
+	require.NoError(t, err)
+	importer := &Importer{RootDir: root, Sites: sites, Client: newTestClient(t, srv)}
+
+	importAll := func(t *testing.T, ids ...int) []ImportResult {
+		t.Helper()
+		var results []ImportResult
+		require.NoError(t, importer.Import(context.Background(), ids, func(res ImportResult) {
+			results = append(results, res)
+		}))
+		return results
+	}
+
+	t.Run("all the articles that aren't mapped to a post", func(t *testing.T) {
+		results := importAll(t)
+		require.Len(t, results, 2)
+		require.NoError(t, results[0].Err)
+		require.NoError(t, results[1].Err)
+		assert.Equal(t, "content/posts/written-on-dev/index.md", rel(root, results[0].Path))
+		assert.Equal(t, []string{"diagram.png", "cover.jpg"}, results[0].Images)
+		assert.Equal(t, "content/posts/draft-written-on-dev/index.md", rel(root, results[1].Path))
+
+		assert.Equal(t, "---\n"+
+			"title: Written on DEV\n"+
+			"description: An article written with DEV's editor.\n"+
+			"date: 2023-05-06T07:08:09Z\n"+
+			"draft: false\n"+
+			"keywords:\n"+
+			"    - go\n"+
+			"    - devto\n"+
+			"series: Go tips\n"+
+			"images:\n"+
+			"    - cover.jpg\n"+
+			"devtoId: 2001\n"+
+			"devtoPublished: true\n"+
+			"devtoUrl: "+srv.URLOf(written)+"\n"+
+			"---\n"+
+			"\n"+
+			"## The `go.mod` file\n"+
+			"\n"+
+			"{{< youtube id=\"30a0WrfaS2A\" >}}\n"+
+			"\n"+
+			"See [the go.mod section](#the-gomod-file) and [the other post](/posts/published/#intro).\n"+
+			"\n"+
+			"![Diagram](diagram.png)\n"+
+			"![Setup](/posts/with-image/setup.png)\n"+
+			"![Gone]("+images.URL+"/gone.png)\n",
+			readFile(t, results[0].Path))
+		assert.Equal(t, "diagram", readFile(t, filepath.Join(root, "content/posts/written-on-dev/diagram.png")))
+		assert.Equal(t, "cover", readFile(t, filepath.Join(root, "content/posts/written-on-dev/cover.jpg")))
+
+		assert.Equal(t, "---\n"+
+			"title: Draft written on DEV\n"+
+			"draft: true\n"+
+			"devtoId: 2002\n"+
+			"devtoPublished: false\n"+
+			"devtoUrl: "+srv.URLOf(draft)+"\n"+
+			"---\n"+
+			"\n"+
+			"Not done yet.\n",
+			readFile(t, results[1].Path))
+	})
+
+	t.Run("already imported, mapped or not the user's", func(t *testing.T) {
+		results := importAll(t, 2001, 1001, 404)
+		require.Len(t, results, 3)
+		assert.EqualError(t, results[0].Err, "\x1b[0;90m"+filepath.Join(root, "content/posts/written-on-dev/index.md")+"\x1b[0m already exists")
+		assert.EqualError(t, results[1].Err, "already mapped to the post \x1b[0;90m/posts/published\x1b[0m")
+		assert.EqualError(t, results[2].Err, "not one of your articles, see 'hudevto devto list'")
+	})
+
+	t.Run("the cover of the imported post is in its page bundle", func(t *testing.T) {
+		sites, err := LoadSites(root, LoadOptions{})
+		require.NoError(t, err)
+		pg := sites.GetContentPage("/content/posts/written-on-dev/index.md")
+		require.NotNil(t, pg)
+		cover, err := pageCover(sites, pg)
+		require.NoError(t, err)
+		assert.Equal(t, "https://blog.example.com/posts/written-on-dev/cover.jpg", cover)
+	})
+}
+
+func Test_liquidToShortcodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		given  string
+		expect string
+	}{
+		{
+			name:   "tag with an equivalent shortcode",
+			given:  "{% youtube 30a0WrfaS2A %}\n",
+			expect: "{{< youtube id=\"30a0WrfaS2A\" >}}\n",
+		},
+		{
+			name:   "paired tag",
+			given:  "{% details Click to see more %}\nHidden.\n{% enddetails %}\n",
+			expect: "{{< details summary=\"Click to see more\" >}}\nHidden.\n{{< /details >}}\n",
+		},
+		{
+			name:   "tag with a positional param",
+			given:  "{% instagram BXgGcAUjM39 %}\n",
+			expect: "{{< instagram \"BXgGcAUjM39\" >}}\n",
+		},
+		{
+			name:   "tags replaced with a link",
+			given:  "{% twitter 1234567890 %}\n{% gist https://gist.github.com/maelvls/abc123 %}\n",
+			expect: "<https://x.com/i/status/1234567890>\n<https://gist.github.com/maelvls/abc123>\n",
+		},
+		{
+			name:   "tag with no equivalent shortcode",
+			given:  "{% embed https://github.com/maelvls/hudevto %}\n",
+			expect: "{% embed https://github.com/maelvls/hudevto %}\n",
+		},
+		{
+			name:   "raw tags are removed",
+			given:  "{% raw %}{{ .Title }}{% endraw %}\n",
+			expect: "{{ .Title }}\n",
+		},
+		{
+			name:   "code blocks and code spans are left untouched",
+			given:  "```go\n{% youtube not-a-tag %}\n```\n\n`{% youtube nope %}`\n",
+			expect: "```go\n{% youtube not-a-tag %}\n```\n\n`{% youtube nope %}`\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, liquidToShortcodes("post.md", tt.given))
+		})
+	}
+}
+
+func Test_hugoAnchorIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		given  string
+		expect string
+	}{
+		{
+			name:   "link to a heading",
+			given:  "## The `go.mod` file\n\nSee [the section](#the-raw-gomod-endraw-file).\n",
+			expect: "## The `go.mod` file\n\nSee [the section](#the-gomod-file).\n",
+		},
+		{
+			name:   "link to an unknown anchor",
+			given:  "## Intro\n\nSee [the section](#conclusion).\n",
+			expect: "## Intro\n\nSee [the section](#conclusion).\n",
+		},
+	}
+	sanitize := map[string]string{"The `go.mod` file": "the-gomod-file", "Intro": "intro"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, hugoAnchorIDs(tt.given, func(s string) string { return sanitize[s] }))
+		})
+	}
+}
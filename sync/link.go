@@ -0,0 +1,215 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/schollz/closestmatch"
+
+	"github.com/maelvls/hudevto/frontmatter"
+)
+
+// MatchKind tells how a post was matched to a DEV article.
+type MatchKind string
+
+const (
+	// The DEV article has the exact same title as the post.
+	MatchTitle MatchKind = "title"
+	// The DEV article's canonical URL is the post's permalink. That's the
+	// case for the articles created by DEV's RSS importer.
+	MatchCanonicalURL MatchKind = "canonical-url"
+	// The DEV article's title is the closest to the post's title and is
+	// similar enough, see minTitleSimilarity. These matches are the least
+	// reliable ones and should be double-checked.
+	MatchFuzzyTitle MatchKind = "fuzzy-title"
+)
+
+// minTitleSimilarity is the minimum similarity between a post's title and the
+// closest DEV article's title for the article to be proposed. Below that, the
+// titles have little more than a few common words and the post is left
+// unmatched.
+const minTitleSimilarity = 0.6
+
+// Link is a proposed mapping between a post that has no devtoId and a DEV
+// article.
+type Link struct {
+	// Path is the path to the post's Markdown file.
+	Path      string
+	Title     string
+	Permalink string
+
+	// Article is nil when no DEV article could be found for the post.
+	Article *devto.ListedArticle
+	Match   MatchKind
+}
+
+// ProposeLinks finds a DEV article for each post that has no devtoId yet.
+// Drafts and posts with devtoSkip are ignored, and so are the DEV articles
+// already mapped to a post. If relPathToArticle isn't empty, only that post is
+// considered, but the articles mapped to the other posts are still ignored.
+func (p *Planner) ProposeLinks(ctx context.Context, relPathToArticle string) ([]Link, error) {
+	pages, err := p.pages(relPathToArticle)
+	if err != nil {
+		return nil, err
+	}
+	allPages, err := p.pages("")
+	if err != nil {
+		return nil, err
+	}
+
+	articles, err := p.Client.ListAllMyArticles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing all the user's articles: %w", err)
+	}
+
+	alreadyLinked := make(map[int]bool)
+	for _, page := range allPages {
+		devtoIdRaw, _ := page.Param("devtoId")
+		if devtoId, ok := intParam(devtoIdRaw); ok {
+			alreadyLinked[devtoId] = true
+		}
+	}
+
+	var links []Link
+	for _, page := range pages {
+		// Posts that already have a devtoId, even an invalid one, are left to
+		// 'hudevto status' to report on.
+		if devtoIdRaw, _ := page.Param("devtoId"); devtoIdRaw != nil {
+			continue
+		}
+		if draft, _ := page.Param("draft"); draft == true {
+			continue
+		}
+		if devtoSkip, _ := page.Param("devtoSkip"); devtoSkip == true {
+			continue
+		}
+
+		pathToMD, err := pagePathToFilePath(p.RootDir, page.Path())
+		if err != nil {
+			return nil, fmt.Errorf("while getting path to MD for %s: %w", page.Path(), err)
+		}
+		links = append(links, Link{Path: pathToMD, Title: page.Title(), Permalink: page.Permalink()})
+	}
+
+	var unlinked []devto.ListedArticle
+	for _, art := range articles {
+		if !alreadyLinked[int(art.ID)] {
+			unlinked = append(unlinked, art)
+		}
+	}
+
+	return matchArticles(links, unlinked), nil
+}
+
+// matchArticles fills in the Article and Match fields of each link. A DEV
+// article is never proposed for two posts. Exact matches (title and canonical
+// URL) are given priority over fuzzy matches.
+func matchArticles(links []Link, articles []devto.ListedArticle) []Link {
+	byTitle := make(map[string]*devto.ListedArticle)
+	byCanonicalURL := make(map[string]*devto.ListedArticle)
+	for i := range articles {
+		art := &articles[i]
+		byTitle[art.Title] = art
+		if art.CanonicalURL != nil && art.CanonicalURL.URL != nil {
+			byCanonicalURL[normalizeURL(art.CanonicalURL.String())] = art
+		}
+	}
+
+	taken := make(map[uint32]bool)
+	for i := range links {
+		link := &links[i]
+		if art, ok := byTitle[link.Title]; ok && !taken[art.ID] {
+			link.Article, link.Match = art, MatchTitle
+		} else if art, ok := byCanonicalURL[normalizeURL(link.Permalink)]; ok && !taken[art.ID] {
+			link.Article, link.Match = art, MatchCanonicalURL
+		} else {
+			continue
+		}
+		taken[link.Article.ID] = true
+	}
+
+	var remaining []string
+	remainingByTitle := make(map[string]*devto.ListedArticle)
+	for i := range articles {
+		art := &articles[i]
+		if taken[art.ID] {
+			continue
+		}
+		remaining = append(remaining, art.Title)
+		remainingByTitle[art.Title] = art
+	}
+	if len(remaining) == 0 {
+		return links
+	}
+
+	matcher := closestmatch.New(remaining, []int{2, 3})
+	for i := range links {
+		link := &links[i]
+		if link.Article != nil {
+			continue
+		}
+		art, ok := remainingByTitle[matcher.Closest(link.Title)]
+		if !ok || taken[art.ID] || titleSimilarity(link.Title, art.Title) < minTitleSimilarity {
+			continue
+		}
+		link.Article, link.Match = art, MatchFuzzyTitle
+		taken[art.ID] = true
+	}
+
+	return links
+}
+
+// titleSimilarity returns the Sørensen–Dice coefficient of the letter pairs of
+// the two titles, ignoring the case: 1 when the titles are the same, 0 when
+// they have no pair in common.
+func titleSimilarity(a, b string) float64 {
+	pairsA, pairsB := letterPairs(a), letterPairs(b)
+	if len(pairsA)+len(pairsB) == 0 {
+		return 0
+	}
+	remaining := make(map[string]int)
+	for _, pair := range pairsB {
+		remaining[pair]++
+	}
+	common := 0
+	for _, pair := range pairsA {
+		if remaining[pair] > 0 {
+			remaining[pair]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(pairsA)+len(pairsB))
+}
+
+// letterPairs returns the pairs of adjacent letters of each word of s, e.g.,
+// "Go tips" gives "go", "ti", "ip" and "ps".
+func letterPairs(s string) []string {
+	var pairs []string
+	for _, word := range strings.Fields(strings.ToLower(s)) {
+		runes := []rune(word)
+		for i := 0; i+1 < len(runes); i++ {
+			pairs = append(pairs, string(runes[i:i+2]))
+		}
+	}
+	return pairs
+}
+
+// The trailing slash is often omitted in canonical URLs.
+func normalizeURL(u string) string {
+	return strings.TrimSuffix(u, "/")
+}
+
+// WriteLink writes the devtoId and devtoPublished fields to the front matter
+// of the post. The devtoPublished value is the one of the DEV article so that
+// the next push doesn't publish or unpublish it.
+func WriteLink(link Link) error {
+	if link.Article == nil {
+		panic("programmer mistake: WriteLink: link.Article cannot be nil")
+	}
+	return frontmatter.SetFields(link.Path,
+		frontmatter.Field{Key: "devtoId", Value: int(link.Article.ID)},
+		frontmatter.Field{Key: "devtoPublished", Value: link.Article.Published},
+	)
+}
@@ -0,0 +1,65 @@
+package sync
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maelvls/hudevto/devtotest"
+)
+
+func Test_matchArticles(t *testing.T) {
+	articles := []devto.ListedArticle{
+		{ID: 1, Title: "Brick Chest"},
+		{ID: 2, Title: "Imported from RSS", CanonicalURL: webURL(t, "https://blog.example/powder-farmer/")},
+		{ID: 3, Title: "Learning Kubernetes controllers"},
+		{ID: 4, Title: "How client-server SSH authentication works"},
+	}
+	links := []Link{
+		{Path: "content/brick-chest.md", Title: "Brick Chest", Permalink: "https://blog.example/brick-chest/"},
+		{Path: "content/powder-farmer/index.md", Title: "Powder Farmer", Permalink: "https://blog.example/powder-farmer"},
+		{Path: "content/k8s.md", Title: "Learning Kubernetes Controllers", Permalink: "https://blog.example/k8s/"},
+		{Path: "content/other.md", Title: "Brick Chest", Permalink: "https://blog.example/other/"},
+		{Path: "content/dns.md", Title: "It's always the DNS' fault", Permalink: "https://blog.example/dns/"},
+	}
+
+	got := matchArticles(links, articles)
+
+	assert.Equal(t, MatchTitle, got[0].Match)
+	assert.Equal(t, uint32(1), got[0].Article.ID)
+	assert.Equal(t, MatchCanonicalURL, got[1].Match)
+	assert.Equal(t, uint32(2), got[1].Article.ID)
+	assert.Equal(t, MatchFuzzyTitle, got[2].Match)
+	assert.Equal(t, uint32(3), got[2].Article.ID)
+
+	// An article is never proposed twice.
+	assert.Nil(t, got[3].Article)
+
+	// The closest title is too different.
+	assert.Nil(t, got[4].Article)
+}
+
+func Test_EndToEnd_ProposeLinks(t *testing.T) {
+	srv := devtotest.NewServer(t)
+	srv.AddArticle(devtotest.Article{ID: 1001, Title: "Brand new post"})
+	planner := newTestPlanner(t, srv, copySite(t), false)
+
+	// The article 1001 has the same title but is already mapped to
+	// content/posts/published.md.
+	links, err := planner.ProposeLinks(context.Background(), "content/posts/new.md")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Nil(t, links[0].Article)
+}
+
+func webURL(t *testing.T, s string) *devto.WebURL {
+	u, err := url.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &devto.WebURL{URL: u}
+}
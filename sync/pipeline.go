@@ -0,0 +1,384 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	gosync "sync"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/resources/page"
+	"gopkg.in/yaml.v3"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// TransformContext is what a Transformer knows about the post whose body is
+// being transformed.
+type TransformContext struct {
+	// Context is the context of the plan. It is canceled on Ctrl+C and is
+	// meant for the network calls, e.g., for uploading images.
+	Context context.Context
+
+	Page page.Page
+
+	// Path is the path to the post's Markdown file. It is meant to be used in
+	// the messages.
+	Path string
+
+	// LineOffset is the number of lines that come before the body in the
+	// Markdown file, i.e., the lines of the front matter. The line n of the
+	// page's RawContent is the line LineOffset+n of the file. It is computed
+	// before the transformers run since they may change the lines of the
+	// body, e.g., "unwrap-soft-breaks".
+	LineOffset int
+
+	// RootDir is the root directory of the Hugo project.
+	RootDir string
+
+	Sites *hugolib.HugoSites
+
+	// Articles are the user's DEV articles by ID. They are used to link to
+	// the other posts on DEV.
+	Articles map[int]*devto.ListedArticle
+
+	// Client is the DEV client, for the transformers that call DEV.
+	Client *Client
+
+	// Push is true when the post is about to be pushed and has passed the
+	// checks of the plan, see Planner.Push. The transformers must not have
+	// side effects, such as uploading images, when it is false.
+	Push bool
+
+	// pendingImages collects the images that the "upload-images" transformer
+	// would have uploaded if Push was true. Nil when nobody is interested.
+	pendingImages *[]string
+
+	// diagnostics collects the messages of the transformers, see Infof,
+	// Warnf and Errorf. When nil, the messages are printed right away.
+	diagnostics *[]Diagnostic
+}
+
+// Infof records an informational message about the post, e.g., an image that
+// was uploaded.
+func (tc TransformContext) Infof(format string, a ...any) {
+	tc.report(DiagnosticInfo, format, a...)
+}
+
+// Warnf records a warning about the post, e.g., a shortcode that has no
+// mapping.
+func (tc TransformContext) Warnf(format string, a ...any) {
+	tc.report(DiagnosticWarning, format, a...)
+}
+
+// Errorf records an error that doesn't stop the post from being pushed, e.g.,
+// a link to an anchor that doesn't exist.
+func (tc TransformContext) Errorf(format string, a ...any) {
+	tc.report(DiagnosticError, format, a...)
+}
+
+func (tc TransformContext) report(level DiagnosticLevel, format string, a ...any) {
+	d := Diagnostic{Level: level, Message: fmt.Sprintf(format, a...)}
+	if tc.diagnostics == nil {
+		d.Print(tc.Path)
+		return
+	}
+	*tc.diagnostics = append(*tc.diagnostics, d)
+}
+
+// DiagnosticLevel is the severity of a Diagnostic.
+type DiagnosticLevel string
+
+const (
+	DiagnosticInfo    DiagnosticLevel = "info"
+	DiagnosticWarning DiagnosticLevel = "warning"
+	DiagnosticError   DiagnosticLevel = "error"
+)
+
+// Diagnostic is a message about a post. Since the posts are planned
+// concurrently, the messages are kept with the post, see
+// PostPlan.Diagnostics, so that they can be printed in the order of the plan.
+type Diagnostic struct {
+	Level DiagnosticLevel
+
+	// Message doesn't include the path of the post.
+	Message string
+}
+
+// Print prints the diagnostic to stderr, prefixed with the path of the post.
+func (d Diagnostic) Print(path string) {
+	switch d.Level {
+	case DiagnosticError:
+		logutil.Errorf("%s: %s", logutil.Gray(path), d.Message)
+	case DiagnosticWarning:
+		logutil.Warnf("%s: %s", logutil.Gray(path), d.Message)
+	default:
+		logutil.Infof("%s: %s", logutil.Gray(path), d.Message)
+	}
+}
+
+// ctx returns the Context, or context.Background when the TransformContext
+// wasn't created by a plan, e.g., in tests.
+func (tc TransformContext) ctx() context.Context {
+	if tc.Context == nil {
+		return context.Background()
+	}
+	return tc.Context
+}
+
+// Transformer changes the body of a post before it is pushed to DEV. The body
+// doesn't include the front matter.
+type Transformer interface {
+	Transform(tc TransformContext, body string) (string, error)
+}
+
+// TransformerFunc turns a func into a Transformer.
+type TransformerFunc func(tc TransformContext, body string) (string, error)
+
+func (f TransformerFunc) Transform(tc TransformContext, body string) (string, error) {
+	return f(tc, body)
+}
+
+// NewTransformerFunc creates a Transformer from the params given in the
+// config. The params are nil when none are given.
+type NewTransformerFunc func(params map[string]any) (Transformer, error)
+
+// TransformerConfig is an entry of Config.Transformers.
+type TransformerConfig struct {
+	Name string `yaml:"name"`
+
+	// Enabled can be set to false to keep the transformer in the list while
+	// disabling it. Defaults to true.
+	Enabled *bool `yaml:"enabled"`
+
+	Params map[string]any `yaml:"params"`
+}
+
+var (
+	registryMu gosync.Mutex
+	registry   = map[string]NewTransformerFunc{}
+)
+
+// RegisterTransformer makes a transformer available under the given name in
+// Config.Transformers. Registering the same name twice replaces the previous
+// transformer.
+func RegisterTransformer(name string, newFunc NewTransformerFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = newFunc
+}
+
+// The built-in transformers. Except for TransformUploadImages, which has to be
+// enabled in the config, they are listed in the order in which they are
+// applied by default.
+const (
+	TransformRefs             = "refs"
+	TransformUnwrapSoftBreaks = "unwrap-soft-breaks"
+	TransformShortcodes       = "shortcodes"
+	TransformAbsoluteURLs     = "absolute-urls"
+	TransformUploadImages     = "upload-images"
+	TransformAnchorIDs        = "anchor-ids"
+)
+
+// DefaultTransformers returns the transformers used when
+// Config.Transformers is nil.
+func DefaultTransformers() []TransformerConfig {
+	return []TransformerConfig{
+		{Name: TransformRefs},
+		{Name: TransformUnwrapSoftBreaks},
+		{Name: TransformShortcodes},
+		{Name: TransformAbsoluteURLs},
+		{Name: TransformAnchorIDs},
+	}
+}
+
+func init() {
+	RegisterTransformer(TransformRefs, withoutParams(resolveRefs))
+	RegisterTransformer(TransformUnwrapSoftBreaks, withoutParams(func(_ TransformContext, body string) (string, error) {
+		return unwrapSoftBreaks(body), nil
+	}))
+	RegisterTransformer(TransformShortcodes, func(params map[string]any) (Transformer, error) {
+		var opts ShortcodeOptions
+		if err := decodeParams(params, &opts); err != nil {
+			return nil, err
+		}
+		c, err := newShortcodeConverter(opts)
+		if err != nil {
+			return nil, err
+		}
+		return TransformerFunc(c.convert), nil
+	})
+	RegisterTransformer(TransformAbsoluteURLs, func(params map[string]any) (Transformer, error) {
+		var opts URLOptions
+		if err := decodeParams(params, &opts); err != nil {
+			return nil, err
+		}
+		r, err := newURLRewriter(opts)
+		if err != nil {
+			return nil, err
+		}
+		return TransformerFunc(r.transform), nil
+	})
+	RegisterTransformer(TransformUploadImages, func(params map[string]any) (Transformer, error) {
+		var opts ImageOptions
+		if err := decodeParams(params, &opts); err != nil {
+			return nil, err
+		}
+		h, err := newImageHost(opts)
+		if err != nil {
+			return nil, err
+		}
+		return TransformerFunc(h.transform), nil
+	})
+	RegisterTransformer(TransformAnchorIDs, withoutParams(func(tc TransformContext, body string) (string, error) {
+		if len(tc.Sites.Sites) == 0 {
+			tc.Errorf("no site found, cannot convert anchor IDs")
+			return body, nil
+		}
+		return convertAnchorIDs(tc, body, tc.Sites.Sites[0].SanitizeAnchorName), nil
+	}))
+}
+
+// withoutParams is for the transformers that take no params.
+func withoutParams(f TransformerFunc) NewTransformerFunc {
+	return func(params map[string]any) (Transformer, error) {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("this transformer doesn't take any params")
+		}
+		return f, nil
+	}
+}
+
+// decodeParams decodes the params of a transformer into the given struct,
+// which is expected to have yaml tags. The unknown params are rejected.
+func decodeParams(params map[string]any, into any) error {
+	if len(params) == 0 {
+		return nil
+	}
+	bytes, err := yaml.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("while reading the params: %w", err)
+	}
+	dec := yaml.NewDecoder(strings.NewReader(string(bytes)))
+	dec.KnownFields(true)
+	if err := dec.Decode(into); err != nil {
+		return fmt.Errorf("while reading the params: %w", err)
+	}
+	return nil
+}
+
+type namedTransformer struct {
+	name string
+	Transformer
+}
+
+// pipeline is the list of the enabled transformers, in order.
+type pipeline []namedTransformer
+
+// newPipeline creates the transformers of the given config. A nil config
+// gives the DefaultTransformers.
+func newPipeline(configs []TransformerConfig) (pipeline, error) {
+	if configs == nil {
+		configs = DefaultTransformers()
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	var p pipeline
+	for _, cfg := range configs {
+		newFunc, ok := registry[cfg.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown transformer %q, the known transformers are: %s", cfg.Name, strings.Join(registeredNames(), ", "))
+		}
+		if cfg.Enabled != nil && !*cfg.Enabled {
+			continue
+		}
+		t, err := newFunc(cfg.Params)
+		if err != nil {
+			return nil, fmt.Errorf("transformer %s: %w", cfg.Name, err)
+		}
+		p = append(p, namedTransformer{name: cfg.Name, Transformer: t})
+	}
+	return p, nil
+}
+
+// Must be called with registryMu held.
+func registeredNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// TransformError is returned when a transformer fails on a post.
+type TransformError struct {
+	Transformer string
+	Err         error
+}
+
+func (e *TransformError) Error() string {
+	return fmt.Sprintf("transformer %s: %s", e.Transformer, e.Err)
+}
+
+func (e *TransformError) Unwrap() error {
+	return e.Err
+}
+
+// run applies the transformers to the body, except the ones listed in skip.
+func (p pipeline) run(tc TransformContext, body string, skip []string) (string, error) {
+	for _, t := range p {
+		if slices.Contains(skip, t.name) {
+			logutil.Debugf("%s: skipping transformer %s", logutil.Gray(tc.Path), t.name)
+			continue
+		}
+		var err error
+		body, err = t.Transform(tc, body)
+		if err != nil {
+			return "", &TransformError{Transformer: t.name, Err: err}
+		}
+	}
+	return body, nil
+}
+
+// skippedTransformers reads the devtoSkipTransformers field, which lets a
+// post opt out of some of the transformers, e.g.,
+//
+//	devtoSkipTransformers: [unwrap-soft-breaks]
+func skippedTransformers(pg page.Page) ([]string, error) {
+	raw, err := pg.Param("devtoSkipTransformers")
+	if err != nil || raw == nil {
+		return nil, nil
+	}
+	var names []string
+	switch v := raw.(type) {
+	case []string:
+		names = v
+	case string:
+		names = []string{v}
+	case []any:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("field devtoSkipTransformers is expected to be a list of strings, got a list containing '%T'", item)
+			}
+			names = append(names, name)
+		}
+	default:
+		return nil, fmt.Errorf("field devtoSkipTransformers is expected to be a list of strings, got '%T'", raw)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, name := range names {
+		if _, ok := registry[name]; !ok {
+			return nil, fmt.Errorf("field devtoSkipTransformers: unknown transformer %q, the known transformers are: %s", name, strings.Join(registeredNames(), ", "))
+		}
+	}
+	return names, nil
+}
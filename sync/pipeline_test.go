@@ -0,0 +1,158 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	// Appends the "suffix" param to the body.
+	RegisterTransformer("test-suffix", func(params map[string]any) (Transformer, error) {
+		suffix, ok := params["suffix"].(string)
+		if !ok {
+			return nil, fmt.Errorf("the param suffix is expected to be a string, got '%T'", params["suffix"])
+		}
+		return TransformerFunc(func(_ TransformContext, body string) (string, error) {
+			return body + suffix, nil
+		}), nil
+	})
+	RegisterTransformer("test-fail", withoutParams(func(_ TransformContext, _ string) (string, error) {
+		return "", errors.New("oops")
+	}))
+}
+
+func Test_newPipeline(t *testing.T) {
+	names := func(p pipeline) []string {
+		var names []string
+		for _, t := range p {
+			names = append(names, t.name)
+		}
+		return names
+	}
+	disabled := false
+
+	t.Run("nil gives the default transformers", func(t *testing.T) {
+		p, err := newPipeline(nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"refs", "unwrap-soft-breaks", "shortcodes", "absolute-urls", "anchor-ids"}, names(p))
+	})
+
+	t.Run("keeps the order and skips the disabled ones", func(t *testing.T) {
+		p, err := newPipeline([]TransformerConfig{
+			{Name: "anchor-ids"},
+			{Name: "unwrap-soft-breaks", Enabled: &disabled},
+			{Name: "shortcodes"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"anchor-ids", "shortcodes"}, names(p))
+	})
+
+	t.Run("empty list gives no transformer", func(t *testing.T) {
+		p, err := newPipeline([]TransformerConfig{})
+		require.NoError(t, err)
+		assert.Empty(t, p)
+	})
+
+	t.Run("unknown transformer", func(t *testing.T) {
+		_, err := newPipeline([]TransformerConfig{{Name: "foo"}})
+		assert.EqualError(t, err, `unknown transformer "foo", the known transformers are: absolute-urls, anchor-ids, refs, shortcodes, test-fail, test-suffix, unwrap-soft-breaks, upload-images`)
+	})
+
+	t.Run("params are given to the transformer", func(t *testing.T) {
+		p, err := newPipeline([]TransformerConfig{{Name: "test-suffix", Params: map[string]any{"suffix": "!"}}})
+		require.NoError(t, err)
+		got, err := p.run(TransformContext{}, "foo", nil)
+		require.NoError(t, err)
+		assert.Equal(t, "foo!", got)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		_, err := newPipeline([]TransformerConfig{{Name: "anchor-ids", Params: map[string]any{"foo": "bar"}}})
+		assert.EqualError(t, err, "transformer anchor-ids: this transformer doesn't take any params")
+	})
+}
+
+func Test_pipeline_run(t *testing.T) {
+	root, sites := loadTestSite(t, map[string]string{
+		"content/posts/wrapped.md": testPost("This post was never\npushed to DEV.\n"),
+		"content/posts/skip.md": "---\n" +
+			"title: Skip\n" +
+			"devtoSkipTransformers: [unwrap-soft-breaks]\n" +
+			"---\n" +
+			"\n" +
+			"This post was never\npushed to DEV.\n",
+		"content/posts/skip-unknown.md": "---\n" +
+			"title: Skip unknown\n" +
+			"devtoSkipTransformers: [foo]\n" +
+			"---\n",
+	})
+
+	tests := []struct {
+		name      string
+		page      string
+		configs   []TransformerConfig
+		expect    string
+		expectErr string
+	}{
+		{
+			name:   "default transformers",
+			page:   "content/posts/wrapped.md",
+			expect: "\nThis post was never pushed to DEV.\n",
+		},
+		{
+			name: "in the order of the config",
+			page: "content/posts/wrapped.md",
+			configs: []TransformerConfig{
+				{Name: "test-suffix", Params: map[string]any{"suffix": "Bye."}},
+				{Name: TransformUnwrapSoftBreaks},
+			},
+			// The suffix is added before the lines are joined.
+			expect: "\nThis post was never pushed to DEV. Bye.",
+		},
+		{
+			name:    "a post can skip a transformer",
+			page:    "content/posts/skip.md",
+			configs: []TransformerConfig{{Name: TransformUnwrapSoftBreaks}},
+			expect:  "\nThis post was never\npushed to DEV.\n",
+		},
+		{
+			name:      "a post skipping an unknown transformer",
+			page:      "content/posts/skip-unknown.md",
+			expectErr: `field devtoSkipTransformers: unknown transformer "foo"`,
+		},
+		{
+			name:      "a failing transformer",
+			page:      "content/posts/wrapped.md",
+			configs:   []TransformerConfig{{Name: "test-fail"}},
+			expectErr: "transformer test-fail: oops",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newPipeline(tt.configs)
+			require.NoError(t, err)
+			tc := newTestContext(t, root, sites, tt.page)
+			var got string
+			skip, err := skippedTransformers(tc.Page)
+			if err == nil {
+				got, err = p.run(tc, tc.Page.RawContent(), skip)
+			}
+			if tt.expectErr != "" {
+				assert.ErrorContains(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func Test_renderFailedReason(t *testing.T) {
+	assert.Equal(t, ReasonTransformFailed, renderFailedReason(&TransformError{Transformer: "test-fail", Err: errors.New("oops")}))
+	assert.Equal(t, ReasonBrokenRef, renderFailedReason(&TransformError{Transformer: TransformRefs, Err: errors.Join(&RefError{Line: 1})}))
+	assert.Equal(t, ReasonInvalidField, renderFailedReason(errors.New("field series has 2 values")))
+}
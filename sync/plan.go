@@ -0,0 +1,525 @@
+// Package sync works out which Hugo posts need to be pushed to DEV and pushes
+// them. The Planner loads the Hugo posts and the DEV articles and produces a
+// Plan; the Executor applies that Plan.
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/resources/page"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// Action is what the Executor will do with a post.
+type Action string
+
+const (
+	// The post is left alone, e.g., because it has no change.
+	ActionSkip Action = "skip"
+	// The post has changes that are not on DEV yet.
+	ActionPush Action = "push"
+	// The post has no devtoId and no DEV article exists for it yet. Only
+	// used when Planner.Create is true.
+	ActionCreate Action = "create"
+	// The post cannot be pushed, e.g., because of a missing field in its
+	// front matter. PostPlan.Err says why.
+	ActionError Action = "error"
+)
+
+// Reason explains why a given Action was chosen.
+type Reason string
+
+const (
+	ReasonDraft            Reason = "draft"
+	ReasonDevtoSkip        Reason = "devto-skip"
+	ReasonNoChange         Reason = "no-change"
+	ReasonChanged          Reason = "changed"
+	ReasonNotOnDevto       Reason = "not-on-devto"
+	ReasonNoSourceFile     Reason = "no-source-file"
+	ReasonInvalidField     Reason = "invalid-field"
+	ReasonMissingPublished Reason = "missing-devto-published"
+	ReasonMissingID        Reason = "missing-devto-id"
+	ReasonUnknownID        Reason = "unknown-devto-id"
+	ReasonSeriesMismatch   Reason = "series-mismatch"
+	ReasonTransformFailed  Reason = "transform-failed"
+	ReasonBrokenRef        Reason = "broken-ref"
+
+	// ReasonPushFailed is not used in plans. It is meant for reporting the
+	// posts whose push failed, see Result.
+	ReasonPushFailed Reason = "push-failed"
+)
+
+// PostPlan is the outcome of planning a single Hugo post.
+type PostPlan struct {
+	// Path is the path to the post's Markdown file, i.e., the Planner's
+	// RootDir joined with the path of the file within the Hugo project. It is
+	// absolute when RootDir is, and relative to the current directory
+	// otherwise, e.g., "content/posts/foo.md" when RootDir is ".". When the
+	// Markdown file can't be found, it is the Hugo path of the page instead.
+	Path string
+
+	Page      page.Page
+	DevtoID   int
+	Published bool
+
+	// Series is the DEV series of the post, see Planner.SeriesField. It is
+	// empty when the post isn't part of a series.
+	Series string
+
+	Action Action
+	Reason Reason
+
+	// Err is only set when Action is ActionError.
+	Err error
+
+	// Markdown is the post as it would be pushed to DEV, i.e., with the
+	// generated front matter and after the transformations. It is empty when
+	// the post couldn't be rendered.
+	Markdown string
+
+	// Article is what would be sent to DEV. Its BodyMarkdown is Markdown.
+	Article Article
+
+	// DroppedTags lists the keywords that didn't make it into the DEV tags,
+	// see TagRules.
+	DroppedTags []string
+
+	// MissingImages lists the images of the post that can't be found. Only
+	// set when Planner.CheckImages is set.
+	MissingImages []MissingImage
+
+	// PendingImages lists the local images of the post that the
+	// "upload-images" transformer hasn't uploaded yet. The images are only
+	// uploaded when pushing, once the post has passed the checks of the
+	// plan. Until then, Markdown points to the images of the blog and the
+	// post is planned with a "body" change since pushing it changes its body.
+	PendingImages []string
+
+	// Diagnostics are the warnings and errors about the post that don't stop
+	// it from being pushed, e.g., a shortcode that has no mapping. They are
+	// kept with the post, rather than printed while planning, so that they
+	// can be printed along with the post in the order of the plan.
+	Diagnostics []Diagnostic
+
+	// Changes lists the fields that differ between the post and the DEV
+	// article when Reason is ReasonChanged, e.g., "tags" or "body". See
+	// compareArticle.
+	Changes []string
+
+	// Remote is the DEV article that the post is mapped to. It is nil when
+	// the post isn't mapped to any DEV article.
+	Remote *devto.ListedArticle
+}
+
+// Plan lists what needs to happen for each post, in the order in which Hugo
+// returned the pages.
+type Plan struct {
+	Posts []PostPlan
+}
+
+// Planner works out what needs to be done for each post so that the DEV
+// articles match the Hugo posts.
+type Planner struct {
+	// RootDir is the root directory of the Hugo project. It cannot be left
+	// empty; if you want to use the current working directory, use ".".
+	RootDir string
+
+	// Sites is the Hugo project loaded with LoadSites.
+	Sites *hugolib.HugoSites
+
+	// Client is used to list the user's DEV articles.
+	Client *Client
+
+	// Create makes the posts that have no devtoId be planned with
+	// ActionCreate instead of failing, as long as no DEV article has the same
+	// title. Without it, the posts need to be mapped to an existing DEV
+	// article, e.g., one created by DEV's RSS importer.
+	Create bool
+
+	// Concurrency is the number of posts rendered and compared with their DEV
+	// article at the same time. Defaults to 1.
+	Concurrency int
+
+	// SeriesField is the front matter field or the taxonomy that holds the
+	// DEV series of a post. Defaults to DefaultSeriesField.
+	SeriesField string
+
+	// Config tells how the posts are rendered for DEV. When nil, it is read
+	// with LoadConfig.
+	Config *Config
+
+	// CheckImages is one of the ImageCheck* values. When set, the images of
+	// each post are checked, see PostPlan.MissingImages. Left empty, the
+	// images aren't checked.
+	CheckImages string
+
+	// HTTP is used for the HEAD requests made when CheckImages is
+	// ImageCheckOnline. Defaults to a client with a 10 seconds timeout.
+	HTTP *http.Client
+
+	// Push is set when the plan is about to be executed. It lets the
+	// transformers do what they can't do for a mere status, such as uploading
+	// the images. The images are uploaded while planning, but only for the
+	// posts that passed the checks, see PostPlan.PendingImages.
+	Push bool
+}
+
+// Plan plans all posts if relPathToArticle is left empty. The relPathToArticle
+// must be a markdown file relative to the root directory, e.g.,
+// "content/post-1/index.md".
+func (p *Planner) Plan(ctx context.Context, relPathToArticle string) (*Plan, error) {
+	if p.RootDir == "" {
+		panic("programmer mistake: Planner.Plan: RootDir cannot be empty")
+	}
+
+	pages, err := p.pages(relPathToArticle)
+	if err != nil {
+		return nil, err
+	}
+
+	articles, err := p.Client.ListAllMyArticles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing all the user's articles: %w", err)
+	}
+
+	articlesIdMap := make(map[int]*devto.ListedArticle)
+	articlesTitleMap := make(map[string]*devto.ListedArticle)
+	for i := range articles {
+		art := &articles[i]
+		articlesIdMap[int(art.ID)] = art
+		articlesTitleMap[art.Title] = art
+	}
+
+	allPages, err := p.pages("")
+	if err != nil {
+		return nil, err
+	}
+	series := indexSeries(allPages, p.seriesField())
+
+	r, err := p.rendering()
+	if err != nil {
+		return nil, err
+	}
+	r.articles = articlesIdMap
+
+	plan := &Plan{Posts: make([]PostPlan, len(pages))}
+	inOrder(len(pages), p.Concurrency, func(i int) PostPlan {
+		return p.planPost(ctx, pages[i], articlesIdMap, articlesTitleMap, series, r)
+	}, func(i int, post PostPlan) {
+		plan.Posts[i] = post
+	})
+
+	return plan, nil
+}
+
+// rendering is what render needs to know in addition to the page. It is the
+// same for all the posts of a plan.
+type rendering struct {
+	tags      TagRules
+	transform pipeline
+	articles  map[int]*devto.ListedArticle
+	images    *imageChecker // Nil when the images aren't checked.
+}
+
+func (p *Planner) rendering() (rendering, error) {
+	cfg := p.Config
+	if cfg == nil {
+		loaded, err := LoadConfig(p.RootDir, p.Sites)
+		if err != nil {
+			return rendering{}, err
+		}
+		cfg = &loaded
+	}
+	transform, err := newPipeline(cfg.Transformers)
+	if err != nil {
+		return rendering{}, fmt.Errorf("while reading the transformers from the config: %w", err)
+	}
+	r := rendering{tags: cfg.Tags, transform: transform}
+	if p.CheckImages != "" {
+		r.images, err = newImageChecker(p.CheckImages, p.HTTP)
+		if err != nil {
+			return rendering{}, err
+		}
+	}
+	return r, nil
+}
+
+func (p *Planner) seriesField() string {
+	if p.SeriesField == "" {
+		return DefaultSeriesField
+	}
+	return p.SeriesField
+}
+
+// pages returns the pages of kind "page", or only the given one if
+// relPathToArticle isn't empty.
+func (p *Planner) pages(relPathToArticle string) ([]page.Page, error) {
+	pages := p.Sites.Pages()
+	if relPathToArticle != "" {
+		// Hugo expects the path to be absolute within the project's root
+		// directory, e.g., "/content/post-1/index.md".
+		pg := p.Sites.GetContentPage(path.Clean("/" + relPathToArticle))
+		if pg == nil {
+			return nil, fmt.Errorf("not found: %s", path.Join(p.RootDir, logutil.Gray(relPathToArticle)))
+		}
+
+		pages = []page.Page{pg}
+	}
+
+	var posts []page.Page
+	for _, pg := range pages {
+		if pg.Kind() != "page" {
+			continue
+		}
+		posts = append(posts, pg)
+	}
+	return posts, nil
+}
+
+func (p *Planner) planPost(ctx context.Context, page page.Page, articlesIdMap map[int]*devto.ListedArticle, articlesTitleMap map[string]*devto.ListedArticle, series seriesIndex, r rendering) PostPlan {
+	post := PostPlan{Path: page.Path(), Page: page}
+
+	// An invalid series field is reported when rendering the post.
+	post.Series, _ = pageSeries(page, p.seriesField())
+	fail := func(reason Reason, err error) PostPlan {
+		post.Action = ActionError
+		post.Reason = reason
+		post.Err = err
+		return post
+	}
+
+	// The pathToMD might either be:
+	//  - article.md             -> a markdown file
+	//  - article/index.md       -> a folder containing an index.md file
+	// Let's find which one it is.
+	pathToMD, err := pagePathToFilePath(p.RootDir, page.Path())
+	if err != nil {
+		return fail(ReasonNoSourceFile, fmt.Errorf("while getting path to MD: %w", err))
+	}
+	post.Path = pathToMD
+
+	draft := true
+	draftRaw, err := page.Param("draft")
+	if draftRaw != nil && err == nil {
+		var ok bool
+		draft, ok = draftRaw.(bool)
+		if !ok {
+			return fail(ReasonInvalidField, fmt.Errorf("field draft is expected to be a boolean, got '%T'", draftRaw))
+		}
+	}
+	if draft {
+		post.Action = ActionSkip
+		post.Reason = ReasonDraft
+		return post
+	}
+
+	devtoSkip := false
+	devtoSkipRaw, err := page.Param("devtoSkip")
+	if devtoSkipRaw != nil && err == nil {
+		var ok bool
+		devtoSkip, ok = devtoSkipRaw.(bool)
+		if !ok {
+			return fail(ReasonInvalidField, fmt.Errorf("field devtoSkip is expected to be a boolean, got '%T'", devtoSkipRaw))
+		}
+	}
+	if devtoSkip {
+		post.Action = ActionSkip
+		post.Reason = ReasonDevtoSkip
+		return post
+	}
+
+	if r.images != nil {
+		post.MissingImages = r.images.check(ctx, p.Sites, page, pathToMD)
+	}
+
+	devtoPublishedRaw, err := page.Param("devtoPublished")
+	if devtoPublishedRaw == nil {
+		return fail(ReasonMissingPublished, fmt.Errorf("missing devtoPublished field"))
+	}
+	if err == nil {
+		var ok bool
+		post.Published, ok = devtoPublishedRaw.(bool)
+		if !ok {
+			return fail(ReasonInvalidField, fmt.Errorf("field devtoPublished is expected to be a boolean, got '%T'", devtoPublishedRaw))
+		}
+	}
+
+	devtoIdRaw, err := page.Param("devtoId")
+	if err != nil || devtoIdRaw == nil {
+		if art, ok := articlesTitleMap[page.Title()]; ok {
+			return fail(ReasonMissingID, fmt.Errorf("missing devtoId field in front matter, might be %s: %s",
+				logutil.Green(strconv.Itoa(int(art.ID))),
+				logutil.Yel(AddEditSegment(art.URL.String(), post.Published)),
+			))
+		}
+		if !p.Create {
+			return fail(ReasonMissingID, fmt.Errorf("missing devtoId field in front matter and title cannot be found on your devto account"))
+		}
+
+		if reason, err := p.renderPost(ctx, &post, series, r); err != nil {
+			return fail(reason, err)
+		}
+		post.Action = ActionCreate
+		post.Reason = ReasonNotOnDevto
+		return post
+	}
+	devtoId, ok := intParam(devtoIdRaw)
+	if !ok {
+		return fail(ReasonInvalidField, fmt.Errorf("field devtoId is expected to be an integer, got '%T'", devtoIdRaw))
+	}
+	post.DevtoID = devtoId
+
+	article, found := articlesIdMap[devtoId]
+	if !found {
+		if art, ok := articlesTitleMap[page.Title()]; ok {
+			return fail(ReasonUnknownID, fmt.Errorf("devtoId %s is unknown but title matches devtoId %s: %s",
+				logutil.Red(strconv.Itoa(devtoId)),
+				logutil.Green(strconv.Itoa(int(art.ID))),
+				logutil.Yel(AddEditSegment(art.URL.String(), post.Published)),
+			))
+		}
+		return fail(ReasonUnknownID, fmt.Errorf("devtoId %s is unknown and title cannot be found in your devto account",
+			logutil.Red(strconv.Itoa(devtoId)),
+		))
+	}
+	post.Remote = article
+
+	if reason, err := p.renderPost(ctx, &post, series, r); err != nil {
+		return fail(reason, err)
+	}
+
+	post.Changes = compareArticle(article, post.Article)
+	if len(post.PendingImages) > 0 && !slices.Contains(post.Changes, "body") {
+		post.Changes = append(post.Changes, "body")
+	}
+	if len(post.Changes) == 0 {
+		post.Action = ActionSkip
+		post.Reason = ReasonNoChange
+		return post
+	}
+
+	post.Action = ActionPush
+	post.Reason = ReasonChanged
+	return post
+}
+
+// renderPost renders the post and checks the result. The images are only
+// uploaded once the checks pass, by rendering the post a second time, so that
+// a post in error doesn't upload anything.
+func (p *Planner) renderPost(ctx context.Context, post *PostPlan, series seriesIndex, r rendering) (Reason, error) {
+	var err error
+	post.Article, post.DroppedTags, err = p.render(ctx, post.Page, post.Path, post.Published, r, false, &post.PendingImages, &post.Diagnostics)
+	if err != nil {
+		return renderFailedReason(err), err
+	}
+	if err := series.check(post.Article.Series); err != nil {
+		return ReasonSeriesMismatch, err
+	}
+	if p.Push && len(post.PendingImages) > 0 {
+		// The second pass repeats the warnings of the first one, plus the
+		// uploads, so its diagnostics replace the ones of the first pass.
+		var diagnostics []Diagnostic
+		post.Article, post.DroppedTags, err = p.render(ctx, post.Page, post.Path, post.Published, r, true, nil, &diagnostics)
+		post.Diagnostics = diagnostics
+		if err != nil {
+			return renderFailedReason(err), err
+		}
+		post.PendingImages = nil
+	}
+	post.Markdown = post.Article.BodyMarkdown
+	return "", nil
+}
+
+func renderFailedReason(err error) Reason {
+	var refErr *RefError
+	if errors.As(err, &refErr) {
+		return ReasonBrokenRef
+	}
+	var transformErr *TransformError
+	if errors.As(err, &transformErr) {
+		return ReasonTransformFailed
+	}
+	return ReasonInvalidField
+}
+
+// render returns the article that would be pushed to DEV for the given page
+// and the keywords that were dropped from its tags. The fields are also
+// written to the front matter of the body since DEV gives precedence to the
+// front matter over the fields. The transformers only have side effects when
+// push is set, see TransformContext.Push; otherwise, the images that would
+// have been uploaded are appended to pendingImages, which may be nil. The
+// messages of the transformers are appended to diagnostics; when nil, they
+// are printed right away.
+func (p *Planner) render(ctx context.Context, page page.Page, pathToMD string, devtoPublished bool, r rendering, push bool, pendingImages *[]string, diagnostics *[]Diagnostic) (Article, []string, error) {
+	img, err := pageCover(p.Sites, page)
+	if err != nil {
+		return Article{}, nil, err
+	}
+
+	orgID := 0
+	orgIDRaw, err := page.Param("devtoOrganizationId")
+	if orgIDRaw != nil && err == nil {
+		var ok bool
+		orgID, ok = intParam(orgIDRaw)
+		if !ok {
+			return Article{}, nil, fmt.Errorf("field devtoOrganizationId is expected to be an integer, got '%T'", orgIDRaw)
+		}
+	}
+
+	series, err := pageSeries(page, p.seriesField())
+	if err != nil {
+		return Article{}, nil, err
+	}
+
+	tags, dropped := r.tags.apply(page.Keywords())
+
+	art := Article{
+		Title:          page.Title(),
+		Description:    page.Description(),
+		Published:      devtoPublished,
+		Tags:           tags,
+		CanonicalURL:   page.Permalink(),
+		MainImage:      img,
+		OrganizationID: orgID,
+		Series:         series,
+	}
+
+	content, err := devtoFrontMatter(art, page.Date())
+	if err != nil {
+		return Article{}, nil, err
+	}
+
+	skip, err := skippedTransformers(page)
+	if err != nil {
+		return Article{}, nil, err
+	}
+	body, err := r.transform.run(TransformContext{
+		Context:    ctx,
+		Page:       page,
+		Path:       pathToMD,
+		LineOffset: bodyLineOffset(pathToMD, page.RawContent()),
+		RootDir:    p.RootDir,
+		Sites:      p.Sites,
+		Articles:   r.articles,
+		Client:     p.Client,
+		Push:       push,
+
+		pendingImages: pendingImages,
+		diagnostics:   diagnostics,
+	}, page.RawContent(), skip)
+	if err != nil {
+		return Article{}, nil, err
+	}
+
+	art.BodyMarkdown = content + body
+	return art, dropped, nil
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hugoPage is embedded under another name since page.Page has a Page method.
+type hugoPage = page.Page
+
+// paramPage overrides a param of the page. Hugo always gives a boolean for
+// the draft param, so an invalid draft field can only be given this way.
+type paramPage struct {
+	hugoPage
+	key   string
+	value any
+}
+
+func (p paramPage) Param(key any) (any, error) {
+	if key == p.key {
+		return p.value, nil
+	}
+	return p.hugoPage.Param(key)
+}
+
+func TestPlanner_planPost_invalidDraft(t *testing.T) {
+	root, sites := loadTestSite(t, nil)
+	pg := sites.GetContentPage("/content/posts/published.md")
+	require.NotNil(t, pg)
+	p := &Planner{RootDir: root, Sites: sites}
+
+	post := p.planPost(context.Background(), paramPage{hugoPage: pg, key: "draft", value: "no"}, nil, nil, nil, rendering{})
+	assert.Equal(t, ActionError, post.Action)
+	assert.Equal(t, ReasonInvalidField, post.Reason)
+	assert.EqualError(t, post.Err, "field draft is expected to be a boolean, got 'string'")
+}
@@ -0,0 +1,191 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// As per https://docs.forem.com/api/#operation/updateArticle, there is a
+// limit of 30 requests per 30 seconds.
+const (
+	DefaultRateLimit       = 30
+	DefaultRateLimitWindow = 30 * time.Second
+	DefaultRetryBudget     = 2 * time.Minute
+)
+
+// The exponential backoff used for 5xx responses and for 429 responses that
+// don't say when to retry starts at minBackoff and doubles at each attempt.
+const (
+	minBackoff = 1 * time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// RetryBudgetError is returned when a request still fails after retrying for
+// as long as the retry budget allows.
+type RetryBudgetError struct {
+	Method     string
+	Path       string
+	Attempts   int
+	Budget     time.Duration
+	LastStatus string
+}
+
+func (e *RetryBudgetError) Error() string {
+	return fmt.Sprintf("giving up on %s %s after %d attempts: the retry budget of %s is exhausted, last response was %s",
+		e.Method, e.Path, e.Attempts, e.Budget, e.LastStatus)
+}
+
+// pacer keeps the requests under the Forem rate limit by making the callers
+// of wait block once limit requests were sent within the last window. It is
+// shared by all the requests of a client so that concurrent pushes are paced
+// too.
+type pacer struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+
+	mu   sync.Mutex
+	sent []time.Time
+	// pausedUntil is set when Forem tells us that no request is left in the
+	// current window.
+	pausedUntil time.Time
+}
+
+func newPacer(limit int, window time.Duration) *pacer {
+	return &pacer{limit: limit, window: window, now: time.Now, sleep: sleepCtx}
+}
+
+// wait blocks until a request can be sent and records it as sent.
+func (p *pacer) wait(ctx context.Context) error {
+	for {
+		p.mu.Lock()
+		now := p.now()
+		for len(p.sent) > 0 && !now.Before(p.sent[0].Add(p.window)) {
+			p.sent = p.sent[1:]
+		}
+
+		var d time.Duration
+		switch {
+		case now.Before(p.pausedUntil):
+			d = p.pausedUntil.Sub(now)
+		case p.limit > 0 && len(p.sent) >= p.limit:
+			d = p.sent[0].Add(p.window).Sub(now)
+		}
+		if d <= 0 {
+			p.sent = append(p.sent, now)
+			p.mu.Unlock()
+			return nil
+		}
+		p.mu.Unlock()
+
+		if err := p.sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// pause makes the next requests wait until the given time.
+func (p *pacer) pause(until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if until.After(p.pausedUntil) {
+		p.pausedUntil = until
+	}
+}
+
+// observe looks at the X-RateLimit-Remaining and X-RateLimit-Reset headers
+// and pauses the requests until the reset when no request is left.
+func (p *pacer) observe(h http.Header) {
+	if h.Get("X-RateLimit-Remaining") != "0" {
+		return
+	}
+	if reset, ok := rateLimitReset(h); ok {
+		p.pause(reset)
+	}
+}
+
+// retryDelay tells whether the response is worth retrying and how long to
+// wait before doing so. A 429 is retried after the Retry-After delay when
+// given. The 5xx responses are retried with a jittered exponential backoff,
+// but only for idempotent methods since a POST may have created the article
+// despite the error, e.g., when a gateway times out while DEV completes the
+// request. A 502, 503 or 504 with a Retry-After header is retried whatever the
+// method since the header tells that the request wasn't processed.
+func retryDelay(method string, resp *http.Response, attempt int, now time.Time) (time.Duration, bool) {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		if d, ok := retryAfter(resp.Header, now); ok {
+			return d, true
+		}
+		if reset, ok := rateLimitReset(resp.Header); ok {
+			return max(reset.Sub(now), 0), true
+		}
+		return backoff(attempt), true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		if d, ok := retryAfter(resp.Header, now); ok {
+			return d, true
+		}
+		if method == "POST" {
+			return 0, false
+		}
+		return backoff(attempt), true
+	case http.StatusInternalServerError:
+		if method == "POST" {
+			return 0, false
+		}
+		return backoff(attempt), true
+	}
+	return 0, false
+}
+
+// The Retry-After header is either a number of seconds or an HTTP date.
+func retryAfter(h http.Header, now time.Time) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return max(time.Duration(secs)*time.Second, 0), true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// The X-RateLimit-Reset header is a Unix timestamp in seconds.
+func rateLimitReset(h http.Header) (time.Time, bool) {
+	secs, err := strconv.ParseInt(h.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0), true
+}
+
+// backoff returns a delay between half and all of minBackoff*2^(attempt-1),
+// capped to maxBackoff. The jitter avoids retrying in lockstep with the
+// other requests that failed at the same time.
+func backoff(attempt int) time.Duration {
+	d := maxBackoff
+	if attempt < 6 {
+		d = min(minBackoff<<(attempt-1), maxBackoff)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/maelvls/hudevto/devtotest"
+)
+
+func Test_pacer(t *testing.T) {
+	t.Run("waits once the limit is reached within the window", func(t *testing.T) {
+		p := newPacer(3, 10*time.Second)
+		clock := fakeClockFor(p)
+
+		for range 3 {
+			require.NoError(t, p.wait(context.Background()))
+			clock.now = clock.now.Add(time.Second)
+		}
+		assert.Empty(t, clock.slept)
+
+		require.NoError(t, p.wait(context.Background()))
+		assert.Equal(t, []time.Duration{7 * time.Second}, clock.slept)
+	})
+
+	t.Run("waits until the reset when no request is left", func(t *testing.T) {
+		p := newPacer(30, 30*time.Second)
+		clock := fakeClockFor(p)
+
+		p.observe(http.Header{
+			"X-Ratelimit-Remaining": {"0"},
+			"X-Ratelimit-Reset":     {"1700000012"},
+		})
+		require.NoError(t, p.wait(context.Background()))
+		assert.Equal(t, []time.Duration{12 * time.Second}, clock.slept)
+	})
+
+	t.Run("gives up when the context is canceled", func(t *testing.T) {
+		p := newPacer(1, time.Hour)
+		require.NoError(t, p.wait(context.Background()))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, p.wait(ctx), context.Canceled)
+	})
+}
+
+func Test_retryDelay(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	resp := func(status int, headers ...string) *http.Response {
+		h := http.Header{}
+		for i := 0; i < len(headers); i += 2 {
+			h.Set(headers[i], headers[i+1])
+		}
+		return &http.Response{StatusCode: status, Header: h}
+	}
+
+	tests := []struct {
+		name      string
+		method    string
+		resp      *http.Response
+		wantRetry bool
+		wantMin   time.Duration
+		wantMax   time.Duration
+	}{
+		{"200 is not retried", "PUT", resp(200), false, 0, 0},
+		{"422 is not retried", "PUT", resp(422), false, 0, 0},
+		{"429 with Retry-After in seconds", "PUT", resp(429, "Retry-After", "5"), true, 5 * time.Second, 5 * time.Second},
+		{"429 with Retry-After as a date", "PUT", resp(429, "Retry-After", now.Add(8*time.Second).UTC().Format(http.TimeFormat)), true, 8 * time.Second, 8 * time.Second},
+		{"429 with X-RateLimit-Reset", "PUT", resp(429, "X-RateLimit-Reset", "1700000020"), true, 20 * time.Second, 20 * time.Second},
+		{"429 without headers backs off", "PUT", resp(429), true, 500 * time.Millisecond, time.Second},
+		{"503 backs off", "PUT", resp(503), true, 500 * time.Millisecond, time.Second},
+		{"504 is not retried for POST", "POST", resp(504), false, 0, 0},
+		{"503 with Retry-After is retried for POST", "POST", resp(503, "Retry-After", "3"), true, 3 * time.Second, 3 * time.Second},
+		{"500 is retried for PUT", "PUT", resp(500), true, 500 * time.Millisecond, time.Second},
+		{"500 is not retried for POST", "POST", resp(500), false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, retry := retryDelay(tt.method, tt.resp, 1, now)
+			assert.Equal(t, tt.wantRetry, retry)
+			assert.GreaterOrEqual(t, got, tt.wantMin)
+			assert.LessOrEqual(t, got, tt.wantMax)
+		})
+	}
+}
+
+func Test_backoff(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 5: 16 * time.Second, 6: 30 * time.Second, 100: 30 * time.Second} {
+		for range 20 {
+			got := backoff(attempt)
+			assert.GreaterOrEqual(t, got, want/2)
+			assert.LessOrEqual(t, got, want)
+		}
+	}
+}
+
+func Test_transport(t *testing.T) {
+	t.Run("honors Retry-After", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		srv.AddArticle(devtotest.Article{ID: 1001, Title: "Foo", Published: true})
+		client, clock := newFakeClockClient(t, srv.URL, srv.APIKey, ClientOptions{})
+
+		srv.Throttle(2, 3*time.Second)
+		art, err := client.UpdateArticle(context.Background(), 1001, Article{BodyMarkdown: "foo"})
+		require.NoError(t, err)
+		assert.Equal(t, "foo", art.BodyMarkdown)
+		assert.Equal(t, []time.Duration{3 * time.Second, 3 * time.Second}, clock.slept)
+		assert.Len(t, srv.Requests(), 3)
+	})
+
+	t.Run("gives up once the budget is exhausted", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		srv.AddArticle(devtotest.Article{ID: 1001, Title: "Foo", Published: true})
+		client, clock := newFakeClockClient(t, srv.URL, srv.APIKey, ClientOptions{RetryBudget: 2 * time.Minute})
+
+		srv.Throttle(10, time.Minute)
+		_, err := client.UpdateArticle(context.Background(), 1001, Article{BodyMarkdown: "foo"})
+		assert.EqualError(t, err, "giving up on PUT /api/articles/1001 after 3 attempts: the retry budget of 2m0s is exhausted, last response was 429 Too Many Requests")
+		assert.Equal(t, []time.Duration{time.Minute, time.Minute}, clock.slept)
+	})
+
+	t.Run("backs off on 5xx", func(t *testing.T) {
+		failures := 2
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": 1001, "body_markdown": "foo"}`))
+		}))
+		defer srv.Close()
+		client, clock := newFakeClockClient(t, srv.URL, "key", ClientOptions{})
+
+		art, err := client.GetArticle(context.Background(), 1001)
+		require.NoError(t, err)
+		assert.Equal(t, "foo", art.BodyMarkdown)
+		require.Len(t, clock.slept, 2)
+		assert.InDelta(t, 750*time.Millisecond, clock.slept[0], float64(250*time.Millisecond))
+		assert.InDelta(t, 1500*time.Millisecond, clock.slept[1], float64(500*time.Millisecond))
+	})
+
+	t.Run("paces the requests", func(t *testing.T) {
+		srv := devtotest.NewServer(t)
+		srv.AddArticle(devtotest.Article{ID: 1001, Title: "Foo", Published: true})
+		client, clock := newFakeClockClient(t, srv.URL, srv.APIKey, ClientOptions{RateLimit: 2, RateLimitWindow: 30 * time.Second})
+
+		for range 3 {
+			_, err := client.GetArticle(context.Background(), 1001)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []time.Duration{30 * time.Second}, clock.slept)
+	})
+}
+
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func fakeClockFor(p *pacer) *fakeClock {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	p.now = func() time.Time { return clock.now }
+	p.sleep = func(ctx context.Context, d time.Duration) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		clock.slept = append(clock.slept, d)
+		clock.now = clock.now.Add(d)
+		return nil
+	}
+	return clock
+}
+
+func newFakeClockClient(t *testing.T, baseURL, apiKey string, opts ClientOptions) (*Client, *fakeClock) {
+	t.Helper()
+	client, err := NewClient(baseURL, apiKey, opts)
+	require.NoError(t, err)
+	return client, fakeClockFor(client.HTTP.Transport.(*transport).pacer)
+}
@@ -0,0 +1,176 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gohugoio/hugo/hugolib"
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// RefError is returned when a ref or relref shortcode points to a page that
+// doesn't exist, e.g., because the page was renamed or is a draft.
+type RefError struct {
+	// Line is the line of the shortcode in the Markdown file.
+	Line int
+
+	// Shortcode is the shortcode as written in the post, e.g.,
+	// {{< ref "other-post.md" >}}.
+	Shortcode string
+	Err       error
+}
+
+func (e *RefError) Error() string {
+	return fmt.Sprintf("line %d: %s: %s", e.Line, e.Shortcode, e.Err)
+}
+
+func (e *RefError) Unwrap() error {
+	return e.Err
+}
+
+// resolveRefs replaces the ref and relref shortcodes with the permalink of
+// the page they point to, e.g.,
+//
+//	[Previous post]({{< relref "other-post.md#conclusion" >}})
+//
+// becomes:
+//
+//	[Previous post](https://maelvls.dev/other-post/#conclusion)
+//
+// Unlike on the blog, the relref shortcodes also give absolute URLs since the
+// relative ones would point to DEV. The pages are looked up by Hugo, so the
+// refs work the same as on the blog. All the refs that can't be resolved are
+// returned as RefErrors.
+func resolveRefs(tc TransformContext, src string) (string, error) {
+	if tc.Page == nil {
+		return "", fmt.Errorf("cannot resolve the refs without a page")
+	}
+
+	// The body may have been changed by the transformers that come before,
+	// so the lines are looked up in the raw body.
+	lines, err := newShortcodeLines(tc)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	var errs []error
+	pos := 0
+	for {
+		tag, found, err := nextShortcodeTag(src, pos)
+		if err != nil {
+			return "", shiftLine(err, tc.LineOffset)
+		}
+		if !found {
+			out.WriteString(src[pos:])
+			break
+		}
+		out.WriteString(src[pos:tag.Start])
+		pos = tag.End
+
+		if tag.Escaped || tag.Closing || (tag.Name != "ref" && tag.Name != "relref") {
+			out.WriteString(src[tag.Start:tag.End])
+			continue
+		}
+
+		sc := shortcode{Name: tag.Name, Params: tag.Params}
+		ref := sc.Get("path")
+		if ref == "" {
+			ref = sc.Get(0)
+		}
+		line := lines.next(tagKey(tag), src, tag.Start)
+		refErr := func(err error) {
+			errs = append(errs, &RefError{Line: line, Shortcode: src[tag.Start:tag.End], Err: err})
+			out.WriteString(src[tag.Start:tag.End])
+		}
+		if ref == "" {
+			refErr(fmt.Errorf("missing the path of the page"))
+			continue
+		}
+
+		// When the page isn't found, Hugo logs a REF_NOT_FOUND error and
+		// returns the refLinksNotFoundURL instead of an error, so the page is
+		// looked up first to report the broken refs only once.
+		if err := checkRef(tc, ref, sc.Get("lang"), sc.Get("outputFormat")); err != nil {
+			refErr(err)
+			continue
+		}
+		link, err := tc.Page.Ref(map[string]any{
+			"path":         ref,
+			"lang":         sc.Get("lang"),
+			"outputFormat": sc.Get("outputFormat"),
+		})
+		if err != nil {
+			refErr(err)
+			continue
+		}
+		out.WriteString(link)
+	}
+
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+	return out.String(), nil
+}
+
+// checkRef tells whether the page that a ref points to exists, looking it up
+// the way Hugo's ref shortcode does: relative to the post first, then from the
+// root of the content directory, and, when the ref has no slash, by its file
+// name anywhere in the content directory.
+func checkRef(tc TransformContext, ref, lang, outputFormat string) error {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return err
+	}
+	if u.Path == "" {
+		// A link to a heading of the post itself, e.g., "#intro".
+		return nil
+	}
+
+	from := tc.Page
+	if lang == "" {
+		lang = from.Language().Lang
+	}
+	var site *hugolib.Site
+	for _, s := range tc.Sites.Sites {
+		if s.Language().Lang == lang {
+			site = s
+		}
+	}
+	if site == nil {
+		return fmt.Errorf("no site found with lang %q", lang)
+	}
+	// The ref is relative to the translation of the post, if any.
+	if lang != from.Language().Lang {
+		from = nil
+		for _, tr := range tc.Page.AllTranslations() {
+			if tr.Language().Lang == lang {
+				from = tr
+			}
+		}
+	}
+
+	var target page.Page
+	if from != nil {
+		target, err = from.GetPage(u.Path)
+	}
+	if err == nil && isNilPage(target) && !strings.Contains(u.Path, "/") {
+		target, err = site.GetPage(u.Path)
+	}
+	switch {
+	case err != nil:
+		return err
+	case isNilPage(target):
+		return fmt.Errorf("page not found")
+	case outputFormat != "" && target.OutputFormats().Get(outputFormat) == nil:
+		return fmt.Errorf("output format %q not found", outputFormat)
+	}
+	return nil
+}
+
+// Hugo's GetPage returns page.NilPage when the page isn't found.
+func isNilPage(pg page.Page) bool {
+	return pg == nil || pg == page.NilPage
+}
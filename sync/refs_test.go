@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_resolveRefs(t *testing.T) {
+	tests := []struct {
+		name      string
+		given     string
+		expect    string
+		expectErr string
+
+		// unwrap runs unwrapSoftBreaks before resolving the refs, like when
+		// "unwrap-soft-breaks" comes first in the config.
+		unwrap bool
+	}{
+		{
+			name: "refs are resolved to absolute permalinks",
+			given: `[a]({{< ref "published.md" >}}) ` +
+				`[b]({{< relref "/posts/with-image/index.md#setup" >}}) ` +
+				`[c]({{% ref path="published" %}}) ` +
+				`[d]({{< ref "#intro" >}}) ` +
+				"`{{</* ref \"escaped.md\" */>}}`\n",
+			expect: "\n" +
+				"[a](https://blog.example.com/posts/published/) " +
+				"[b](https://blog.example.com/posts/with-image/#setup) " +
+				"[c](https://blog.example.com/posts/published/) " +
+				"[d](#intro) " +
+				"`{{</* ref \"escaped.md\" */>}}`\n",
+		},
+		{
+			name: "unresolvable refs",
+			given: "[a]({{< ref \"missing.md\" >}})\n\n" +
+				"[b]({{< relref \"draft.md\" >}})\n\n" +
+				"[c]({{< ref >}})\n\n" +
+				"[d]({{< ref path=\"published.md\" outputFormat=\"amp\" >}})\n",
+			expectErr: "line 7: {{< ref \"missing.md\" >}}: page not found\n" +
+				"line 9: {{< relref \"draft.md\" >}}: page not found\n" +
+				"line 11: {{< ref >}}: missing the path of the page\n" +
+				"line 13: {{< ref path=\"published.md\" outputFormat=\"amp\" >}}: output format \"amp\" not found",
+		},
+		{
+			name:      "the lines are the ones of the file when the paragraphs are unwrapped first",
+			given:     "Some\nwrapped text, [a]({{< ref \"published.md\" >}})\nand [b]({{< ref \"missing.md\" >}}).\n",
+			unwrap:    true,
+			expectErr: "line 9: {{< ref \"missing.md\" >}}: page not found",
+		},
+	}
+
+	files := make(map[string]string)
+	for i, tt := range tests {
+		files[fmt.Sprintf("content/posts/refs-%d.md", i)] = testPost(tt.given)
+	}
+	root, sites := loadTestSite(t, files)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := newTestContext(t, root, sites, fmt.Sprintf("content/posts/refs-%d.md", i))
+			body := tc.Page.RawContent()
+			if tt.unwrap {
+				body = unwrapSoftBreaks(body)
+			}
+			var got string
+			var err error
+			stderr := stderrOf(t, func() {
+				got, err = resolveRefs(tc, body)
+			})
+			// The broken refs are only reported by hudevto, not by Hugo.
+			assert.NotContains(t, stderr, "REF_NOT_FOUND")
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+// stderrOf returns what was written to stderr while running f.
+func stderrOf(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	outCh := make(chan string)
+	go func() {
+		out, _ := io.ReadAll(r)
+		outCh <- string(out)
+	}()
+	f()
+	w.Close()
+	return <-outCh
+}
@@ -0,0 +1,203 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options configures ImageBackendS3. The credentials are read from the
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and, optionally, AWS_SESSION_TOKEN
+// environment variables so that they don't end up in the config.
+type S3Options struct {
+	// Endpoint is the URL of the S3 API, e.g.,
+	// https://s3.eu-west-3.amazonaws.com or, for Cloudflare R2,
+	// https://<account>.r2.cloudflarestorage.com. The bucket is given in the
+	// path of the requests, i.e., path-style.
+	Endpoint string `yaml:"endpoint"`
+
+	// Region is used for signing the requests. Defaults to "auto", which is
+	// what R2 expects.
+	Region string `yaml:"region"`
+
+	Bucket string `yaml:"bucket"`
+
+	// Prefix is prepended to the names of the images, e.g., "devto/".
+	Prefix string `yaml:"prefix"`
+
+	// URL is the public URL of the bucket, e.g.,
+	// https://images.example.com/. The images are expected to be readable
+	// by anyone at URL + Prefix + name.
+	URL string `yaml:"url"`
+}
+
+func (o S3Options) validate() error {
+	var missing []string
+	for _, field := range []struct{ name, value string }{
+		{"endpoint", o.Endpoint},
+		{"bucket", o.Bucket},
+		{"url", o.URL},
+	} {
+		if field.value == "" {
+			missing = append(missing, field.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+type s3Uploader struct {
+	opts                 S3Options
+	accessKey, secretKey string
+	sessionToken         string
+	http                 *http.Client
+	now                  func() time.Time
+}
+
+func newS3Uploader(opts S3Options) (*s3Uploader, error) {
+	if opts.Region == "" {
+		opts.Region = "auto"
+	}
+	u := &s3Uploader{
+		opts:         opts,
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		http:         http.DefaultClient,
+		now:          time.Now,
+	}
+	if u.accessKey == "" || u.secretKey == "" {
+		return nil, fmt.Errorf("s3: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+	return u, nil
+}
+
+// upload puts the image in the bucket with a PutObject request.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObject.html
+func (u *s3Uploader) upload(ctx context.Context, name string, content []byte) (string, error) {
+	key := u.opts.Prefix + name
+	endpoint := strings.TrimSuffix(u.opts.Endpoint, "/")
+	objectURL := endpoint + "/" + uriEncode(u.opts.Bucket, false) + "/" + uriEncode(key, false)
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", objectURL, bytes.NewReader(content))
+	if err != nil {
+		return "", fmt.Errorf("creating HTTP request for PUT %s: %w", objectURL, err)
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	sum := sha256.Sum256(content)
+	if u.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", u.sessionToken)
+	}
+	signV4(req, hex.EncodeToString(sum[:]), u.accessKey, u.secretKey, u.opts.Region, u.now())
+
+	resp, err := u.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("while doing PUT %s: %w", objectURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("PUT %s: %s: %s", objectURL, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return strings.TrimSuffix(u.opts.URL, "/") + "/" + uriEncode(key, false), nil
+}
+
+// signV4 signs the request for S3 using AWS Signature Version 4. The host and
+// all the headers already set on the request are signed.
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	var params []string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes everything but the unreserved characters, as expected by
+// AWS. The slashes are kept unless encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+package sync
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gohugoio/hugo/resources/page"
+)
+
+// DefaultSeriesField is the front matter field (or taxonomy) used for the DEV
+// series when Planner.SeriesField is empty.
+const DefaultSeriesField = "series"
+
+// pageSeries returns the DEV series of the page. The field is either a front
+// matter field holding a string, or a taxonomy, in which case the front
+// matter holds a list of terms. Since a DEV article belongs to at most one
+// series, a list with more than one term is an error.
+func pageSeries(pg page.Page, field string) (string, error) {
+	raw, err := pg.Param(field)
+	if err != nil || raw == nil {
+		return "", nil
+	}
+
+	var names []string
+	switch v := raw.(type) {
+	case string:
+		names = []string{v}
+	case []string:
+		names = v
+	case []any:
+		for _, item := range v {
+			name, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("field %s is expected to be a string or a list of strings, got a list containing '%T'", field, item)
+			}
+			names = append(names, name)
+		}
+	default:
+		return "", fmt.Errorf("field %s is expected to be a string or a list of strings, got '%T'", field, raw)
+	}
+
+	var nonEmpty []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			nonEmpty = append(nonEmpty, name)
+		}
+	}
+	switch len(nonEmpty) {
+	case 0:
+		return "", nil
+	case 1:
+		return nonEmpty[0], nil
+	}
+	return "", fmt.Errorf("field %s has %d values (%s) but a DEV article can only be part of one series", field, len(nonEmpty), strings.Join(nonEmpty, ", "))
+}
+
+var nonAlphaNum = regexp.MustCompile(`[^\p{L}\p{N}]+`)
+
+// seriesKey is the same for the names that Hugo would consider the same term,
+// e.g., "Go Tips" and "go-tips", but that DEV would see as two series.
+func seriesKey(name string) string {
+	return strings.Trim(nonAlphaNum.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+// seriesSpelling is one of the ways a series is spelled across the posts.
+type seriesSpelling struct {
+	Name  string
+	Pages []string
+}
+
+// seriesIndex maps the key of each series to its spellings, most used first.
+// Ties are broken by the order in which Hugo returned the pages.
+type seriesIndex map[string][]seriesSpelling
+
+// indexSeries looks at the series of all the posts, not only the ones being
+// planned, so that a post can be checked against the rest of its series.
+// Posts with an invalid series field are ignored here and reported when
+// planned.
+func indexSeries(pages []page.Page, field string) seriesIndex {
+	idx := seriesIndex{}
+	for _, pg := range pages {
+		name, err := pageSeries(pg, field)
+		if err != nil || name == "" {
+			continue
+		}
+		key := seriesKey(name)
+		found := false
+		for i := range idx[key] {
+			if idx[key][i].Name == name {
+				idx[key][i].Pages = append(idx[key][i].Pages, pg.Path())
+				found = true
+				break
+			}
+		}
+		if !found {
+			idx[key] = append(idx[key], seriesSpelling{Name: name, Pages: []string{pg.Path()}})
+		}
+	}
+	for key := range idx {
+		sort.SliceStable(idx[key], func(i, j int) bool {
+			return len(idx[key][i].Pages) > len(idx[key][j].Pages)
+		})
+	}
+	return idx
+}
+
+// check returns an error when the series name isn't spelled like in most of
+// the other posts of the series.
+func (idx seriesIndex) check(name string) error {
+	spellings := idx[seriesKey(name)]
+	if name == "" || len(spellings) < 2 || spellings[0].Name == name {
+		return nil
+	}
+	return fmt.Errorf("series %q is spelled %q in %d other post(s), e.g., %s; all the posts of a series must use the same name",
+		name,
+		spellings[0].Name,
+		len(spellings[0].Pages),
+		spellings[0].Pages[0],
+	)
+}
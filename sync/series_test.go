@@ -0,0 +1,89 @@
+package sync
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_seriesKey(t *testing.T) {
+	assert.Equal(t, "go-tips", seriesKey("Go Tips"))
+	assert.Equal(t, "go-tips", seriesKey("go-tips"))
+	assert.Equal(t, "go-tips", seriesKey(" Go  tips! "))
+	assert.Equal(t, "café-crème", seriesKey("Café Crème"))
+}
+
+func Test_seriesIndex_check(t *testing.T) {
+	idx := seriesIndex{
+		"go-tips": {
+			{Name: "Go Tips", Pages: []string{"/posts/a", "/posts/b"}},
+			{Name: "go tips", Pages: []string{"/posts/c"}},
+		},
+		"hugo": {
+			{Name: "Hugo", Pages: []string{"/posts/d"}},
+		},
+	}
+	assert.NoError(t, idx.check(""))
+	assert.NoError(t, idx.check("Go Tips"))
+	assert.NoError(t, idx.check("Hugo"))
+	assert.EqualError(t, idx.check("go tips"), `series "go tips" is spelled "Go Tips" in 2 other post(s), e.g., /posts/a; all the posts of a series must use the same name`)
+}
+
+func Test_pageSeries(t *testing.T) {
+	tests := []struct {
+		name        string
+		frontMatter string
+		expect      string
+		expectErr   string
+	}{
+		{name: "string", frontMatter: "series: Hugo to DEV", expect: "Hugo to DEV"},
+		{name: "list of one term", frontMatter: "series: [Hugo to DEV]", expect: "Hugo to DEV"},
+		{name: "empty term", frontMatter: "series: [' ']", expect: ""},
+		{name: "no series", expect: ""},
+		{
+			name:        "several series",
+			frontMatter: "series: [Hugo to DEV, Go]",
+			expectErr:   "field series has 2 values (Hugo to DEV, Go) but a DEV article can only be part of one series",
+		},
+	}
+
+	files := make(map[string]string)
+	for i, tt := range tests {
+		files[fmt.Sprintf("content/posts/series-%d.md", i)] = "---\ntitle: Series " + tt.name + "\n" + tt.frontMatter + "\n---\n"
+	}
+	_, sites := loadTestSite(t, files)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pg := sites.GetContentPage(fmt.Sprintf("/content/posts/series-%d.md", i))
+			require.NotNil(t, pg)
+			got, err := pageSeries(pg, DefaultSeriesField)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
+
+func Test_indexSeries(t *testing.T) {
+	_, sites := loadTestSite(t, map[string]string{
+		"content/posts/a.md": "---\ntitle: A\nseries: Hugo to DEV\n---\n",
+		"content/posts/b.md": "---\ntitle: B\nseries: [Hugo to DEV]\n---\n",
+		"content/posts/c.md": "---\ntitle: C\nseries: hugo to dev\n---\n",
+		"content/posts/d.md": "---\ntitle: D\nseries: [Hugo to DEV, Go]\n---\n",
+	})
+	idx := indexSeries(sites.Pages(), DefaultSeriesField)
+
+	// The invalid series of d.md is ignored.
+	assert.Equal(t, seriesIndex{
+		"hugo-to-dev": {
+			{Name: "Hugo to DEV", Pages: []string{"/posts/a", "/posts/b"}},
+			{Name: "hugo to dev", Pages: []string{"/posts/c"}},
+		},
+	}, idx)
+}
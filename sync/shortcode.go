@@ -0,0 +1,659 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// ShortcodeMapping tells what a Hugo shortcode becomes in the DEV body. One of
+// Liquid, Template or Hugo must be set.
+type ShortcodeMapping struct {
+	// Liquid is the name of the DEV Liquid tag that replaces the shortcode,
+	// e.g., "youtube" for {% youtube 30a0WrfaS2A %}. When the shortcode has
+	// inner content, e.g., {{< details >}}...{{< /details >}}, the Liquid tag
+	// is closed with {% enddetails %}.
+	Liquid string `yaml:"liquid"`
+
+	// Args lists the params of the shortcode that are given to the Liquid
+	// tag, in order. Each arg is either the name of a named param or the
+	// position of a positional param, e.g., "id" or "0". Alternatives can be
+	// given with "|", e.g., "id|0" for a shortcode that accepts both forms.
+	// When empty, all the params are given in the order they appear.
+	Args []string `yaml:"args"`
+
+	// Template is a Go template whose output, Markdown or HTML, replaces the
+	// shortcode. Like in Hugo, {{ .Get "id" }} and {{ .Get 0 }} return the
+	// params, and {{ .Inner }} returns the inner content. The chomp and trim
+	// funcs remove the trailing newlines and the surrounding whitespace.
+	Template string `yaml:"template"`
+
+	// Hugo makes Hugo render the shortcode using the site's shortcode
+	// templates, e.g., layouts/shortcodes/callout.html, and the HTML output
+	// replaces the shortcode. It is meant for the shortcodes that have no
+	// DEV equivalent.
+	Hugo bool `yaml:"hugo"`
+}
+
+// What to do with the shortcodes that aren't in the mapping table.
+const (
+	// The shortcode is left as is and a warning is printed.
+	ShortcodeFallbackKeep = "keep"
+	// The shortcode is removed and a warning is printed. The inner content
+	// of a paired shortcode is kept.
+	ShortcodeFallbackDrop = "drop"
+	// The post is in error.
+	ShortcodeFallbackError = "error"
+	// The shortcode is converted to the Liquid tag of the same name, with
+	// all its params, which is what hudevto used to do.
+	ShortcodeFallbackLiquid = "liquid"
+	// The shortcode is rendered by Hugo, see ShortcodeMapping.Hugo.
+	ShortcodeFallbackHugo = "hugo"
+)
+
+// ShortcodeOptions are the params of the "shortcodes" transformer.
+type ShortcodeOptions struct {
+	// Shortcodes is merged with DefaultShortcodes, the entries given here
+	// replacing the default ones.
+	Shortcodes map[string]ShortcodeMapping `yaml:"shortcodes"`
+
+	// Fallback is one of the ShortcodeFallback* values. Defaults to
+	// ShortcodeFallbackKeep.
+	Fallback string `yaml:"fallback"`
+}
+
+// DefaultShortcodes maps Hugo's built-in shortcodes to the DEV Liquid tags.
+func DefaultShortcodes() map[string]ShortcodeMapping {
+	return map[string]ShortcodeMapping{
+		"youtube":   {Liquid: "youtube", Args: []string{"id|0"}},
+		"vimeo":     {Liquid: "vimeo", Args: []string{"id|0"}},
+		"instagram": {Liquid: "instagram", Args: []string{"0|id"}},
+		"tweet":     {Liquid: "twitter", Args: []string{"id|1|0"}},
+		"x":         {Liquid: "twitter", Args: []string{"id"}},
+		"gist":      {Template: `{% gist https://gist.github.com/{{ .Get 0 }}/{{ .Get 1 }}{{ with .Get 2 }} file={{ . }}{{ end }} %}`},
+		"details":   {Liquid: "details", Args: []string{"summary|0"}},
+		"highlight": {Template: "```{{ .Get 0 }}{{ .Inner | chomp }}\n```"},
+	}
+}
+
+// shortcodeParam is a param of a shortcode. Name is empty for the positional
+// params.
+type shortcodeParam struct {
+	Name, Value string
+}
+
+// shortcode is a shortcode found in the body. Start and End are the offsets of
+// the whole shortcode, including the closing shortcode and the inner content
+// of a paired shortcode.
+type shortcode struct {
+	Name       string
+	Params     []shortcodeParam
+	Inner      string
+	Paired     bool
+	Start, End int
+}
+
+// Get returns the positional param at the given position or the named param
+// with the given name, like Hugo's .Get. It is meant to be used in templates.
+func (sc shortcode) Get(key any) string {
+	switch key := key.(type) {
+	case int:
+		i := 0
+		for _, p := range sc.Params {
+			if p.Name != "" {
+				continue
+			}
+			if i == key {
+				return p.Value
+			}
+			i++
+		}
+	case string:
+		for _, p := range sc.Params {
+			if p.Name == key {
+				return p.Value
+			}
+		}
+	}
+	return ""
+}
+
+// has is like Get but tells whether the param exists.
+func (sc shortcode) has(key string) (string, bool) {
+	if pos, err := strconv.Atoi(key); err == nil {
+		i := 0
+		for _, p := range sc.Params {
+			if p.Name == "" {
+				if i == pos {
+					return p.Value, true
+				}
+				i++
+			}
+		}
+		return "", false
+	}
+	for _, p := range sc.Params {
+		if p.Name == key {
+			return p.Value, true
+		}
+	}
+	return "", false
+}
+
+// shortcodeTag is a single {{< ... >}} or {{% ... %}}.
+type shortcodeTag struct {
+	Name       string
+	Params     []shortcodeParam
+	Closing    bool // {{< /name >}}
+	SelfClosed bool // {{< name />}}
+	// Escaped is true for {{</* name */>}}, which Hugo outputs as {{< name >}}.
+	Escaped    bool
+	Start, End int
+}
+
+// shortcodeSyntaxError is returned when a shortcode can't be read. Line is
+// relative to the string being read.
+type shortcodeSyntaxError struct {
+	Line int
+	Err  string
+}
+
+func (e *shortcodeSyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// shiftLine adds the offset to the line of a shortcodeSyntaxError, e.g., so
+// that the line is within the Markdown file instead of within the body. The
+// other errors are returned as is.
+func shiftLine(err error, offset int) error {
+	var syntaxErr *shortcodeSyntaxError
+	if errors.As(err, &syntaxErr) {
+		return &shortcodeSyntaxError{Line: offset + syntaxErr.Line, Err: syntaxErr.Err}
+	}
+	return err
+}
+
+// nextShortcodeTag returns the first shortcode tag found in src at or after
+// the offset from. The returned bool is false when there is none left.
+func nextShortcodeTag(src string, from int) (shortcodeTag, bool, error) {
+	for {
+		i := strings.Index(src[from:], "{{")
+		if i == -1 {
+			return shortcodeTag{}, false, nil
+		}
+		start := from + i
+		if start+2 >= len(src) || (src[start+2] != '<' && src[start+2] != '%') {
+			from = start + 2
+			continue
+		}
+		closeDelim := "%}}"
+		if src[start+2] == '<' {
+			closeDelim = ">}}"
+		}
+
+		pos := start + 3
+		skipSpaces := func() {
+			for pos < len(src) && strings.ContainsRune(" \t\r\n", rune(src[pos])) {
+				pos++
+			}
+		}
+		skipSpaces()
+
+		if strings.HasPrefix(src[pos:], "/*") {
+			end := strings.Index(src[pos:], "*/")
+			if end == -1 {
+				return shortcodeTag{}, false, &shortcodeSyntaxError{Line: lineAt(src, start), Err: "unterminated escaped shortcode"}
+			}
+			rest := pos + end + 2
+			j := strings.Index(src[rest:], closeDelim)
+			if j == -1 || strings.TrimSpace(src[rest:rest+j]) != "" {
+				return shortcodeTag{}, false, &shortcodeSyntaxError{Line: lineAt(src, start), Err: "unterminated escaped shortcode"}
+			}
+			return shortcodeTag{
+				Name:    strings.TrimSpace(src[pos+2 : pos+end]),
+				Escaped: true,
+				Start:   start,
+				End:     rest + j + len(closeDelim),
+			}, true, nil
+		}
+
+		tag := shortcodeTag{Start: start}
+		if strings.HasPrefix(src[pos:], "/") {
+			tag.Closing = true
+			pos++
+			skipSpaces()
+		}
+
+		var words []string
+		for {
+			skipSpaces()
+			if pos >= len(src) {
+				return shortcodeTag{}, false, &shortcodeSyntaxError{Line: lineAt(src, start), Err: "unterminated shortcode"}
+			}
+			if strings.HasPrefix(src[pos:], closeDelim) {
+				tag.End = pos + len(closeDelim)
+				break
+			}
+			if strings.HasPrefix(src[pos:], "/"+closeDelim) {
+				tag.SelfClosed = true
+				tag.End = pos + 1 + len(closeDelim)
+				break
+			}
+
+			word, name, err := readShortcodeWord(src, &pos, closeDelim)
+			if err != nil {
+				return shortcodeTag{}, false, &shortcodeSyntaxError{Line: lineAt(src, start), Err: err.Error()}
+			}
+			if len(words) == 0 {
+				tag.Name = word
+			} else {
+				tag.Params = append(tag.Params, shortcodeParam{Name: name, Value: word})
+			}
+			words = append(words, word)
+		}
+		if tag.Name == "" {
+			return shortcodeTag{}, false, &shortcodeSyntaxError{Line: lineAt(src, start), Err: "shortcode without a name"}
+		}
+		return tag, true, nil
+	}
+}
+
+// readShortcodeWord reads a param, which is either a positional value or a
+// name=value pair. The values can be bare words, "quoted" or `raw`.
+func readShortcodeWord(src string, pos *int, closeDelim string) (value, name string, err error) {
+	readValue := func() (string, error) {
+		switch {
+		case *pos < len(src) && src[*pos] == '"':
+			var b strings.Builder
+			for i := *pos + 1; i < len(src); i++ {
+				switch src[i] {
+				case '\\':
+					if i+1 < len(src) {
+						i++
+						b.WriteByte(src[i])
+					}
+				case '"':
+					*pos = i + 1
+					return b.String(), nil
+				default:
+					b.WriteByte(src[i])
+				}
+			}
+			return "", fmt.Errorf("unterminated quoted string in shortcode")
+		case *pos < len(src) && src[*pos] == '`':
+			end := strings.IndexByte(src[*pos+1:], '`')
+			if end == -1 {
+				return "", fmt.Errorf("unterminated raw string in shortcode")
+			}
+			v := src[*pos+1 : *pos+1+end]
+			*pos += end + 2
+			return v, nil
+		}
+		start := *pos
+		for *pos < len(src) && !strings.ContainsRune(" \t\r\n=", rune(src[*pos])) &&
+			!strings.HasPrefix(src[*pos:], closeDelim) && !strings.HasPrefix(src[*pos:], "/"+closeDelim) {
+			*pos++
+		}
+		return src[start:*pos], nil
+	}
+
+	value, err = readValue()
+	if err != nil {
+		return "", "", err
+	}
+	if *pos < len(src) && src[*pos] == '=' {
+		*pos++
+		name = value
+		value, err = readValue()
+		if err != nil {
+			return "", "", err
+		}
+	}
+	return value, name, nil
+}
+
+// parseShortcode reads the shortcode that starts with the given opening tag.
+// When a matching closing tag is found, the shortcode is paired and its inner
+// content is what is between the two tags.
+func parseShortcode(src string, open shortcodeTag) (shortcode, error) {
+	sc := shortcode{Name: open.Name, Params: open.Params, Start: open.Start, End: open.End}
+	if open.SelfClosed {
+		return sc, nil
+	}
+
+	depth := 0
+	pos := open.End
+	for {
+		tag, found, err := nextShortcodeTag(src, pos)
+		if err != nil {
+			return shortcode{}, err
+		}
+		if !found {
+			return sc, nil
+		}
+		pos = tag.End
+		if tag.Escaped || tag.Name != open.Name || tag.SelfClosed {
+			continue
+		}
+		if !tag.Closing {
+			depth++
+			continue
+		}
+		if depth > 0 {
+			depth--
+			continue
+		}
+		sc.Paired = true
+		sc.Inner = src[open.End:tag.Start]
+		sc.End = tag.End
+		return sc, nil
+	}
+}
+
+func lineAt(src string, offset int) int {
+	return strings.Count(src[:offset], "\n") + 1
+}
+
+// shortcodeLines gives the lines, in the Markdown file, of the shortcode tags
+// of a post. The body given to a transformer may have been changed by the
+// transformers that come before it, e.g., the lines of the
+// paragraphs may have been joined, so the tags are looked up in the raw body
+// instead, by name and in order. This works since the other transformers
+// don't add nor remove the shortcodes that this transformer converts.
+type shortcodeLines struct {
+	lines map[string][]int // By tag name, e.g., "youtube" or "/details".
+}
+
+func newShortcodeLines(tc TransformContext) (*shortcodeLines, error) {
+	l := &shortcodeLines{lines: make(map[string][]int)}
+	if tc.Page == nil {
+		return l, nil
+	}
+	raw := tc.Page.RawContent()
+	for pos := 0; ; {
+		tag, found, err := nextShortcodeTag(raw, pos)
+		if err != nil {
+			return nil, shiftLine(err, tc.LineOffset)
+		}
+		if !found {
+			return l, nil
+		}
+		pos = tag.End
+		if !tag.Escaped {
+			l.lines[tagKey(tag)] = append(l.lines[tagKey(tag)], tc.LineOffset+lineAt(raw, tag.Start))
+		}
+	}
+}
+
+func tagKey(tag shortcodeTag) string {
+	if tag.Closing {
+		return "/" + tag.Name
+	}
+	return tag.Name
+}
+
+// next returns the line of the next tag with the given key. When the raw body
+// doesn't have it, e.g., in tests, the line within src is returned instead.
+func (l *shortcodeLines) next(key, src string, offset int) int {
+	if len(l.lines[key]) == 0 {
+		return lineAt(src, offset)
+	}
+	line := l.lines[key][0]
+	l.lines[key] = l.lines[key][1:]
+	return line
+}
+
+// skip skips the tags of the inner content of a shortcode whose inner content
+// isn't converted.
+func (l *shortcodeLines) skip(inner string) {
+	for pos := 0; ; {
+		tag, found, err := nextShortcodeTag(inner, pos)
+		if err != nil || !found {
+			return
+		}
+		pos = tag.End
+		if !tag.Escaped {
+			l.next(tagKey(tag), inner, tag.Start)
+		}
+	}
+}
+
+// shortcodeConverter converts the Hugo shortcodes to what DEV understands.
+type shortcodeConverter struct {
+	mappings  map[string]ShortcodeMapping
+	templates map[string]*template.Template
+	fallback  string
+}
+
+var shortcodeFuncs = template.FuncMap{
+	"chomp": func(s string) string { return strings.TrimRight(s, "\r\n") },
+	"trim":  strings.TrimSpace,
+}
+
+func newShortcodeConverter(opts ShortcodeOptions) (*shortcodeConverter, error) {
+	c := &shortcodeConverter{
+		mappings:  DefaultShortcodes(),
+		templates: make(map[string]*template.Template),
+		fallback:  opts.Fallback,
+	}
+	for name, m := range opts.Shortcodes {
+		c.mappings[name] = m
+	}
+
+	switch c.fallback {
+	case "":
+		c.fallback = ShortcodeFallbackKeep
+	case ShortcodeFallbackKeep, ShortcodeFallbackDrop, ShortcodeFallbackError, ShortcodeFallbackLiquid, ShortcodeFallbackHugo:
+	default:
+		return nil, fmt.Errorf("unknown fallback %q, expected one of keep, drop, error, liquid or hugo", c.fallback)
+	}
+
+	names := make([]string, 0, len(c.mappings))
+	for name := range c.mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m := c.mappings[name]
+		set := 0
+		for _, isSet := range []bool{m.Liquid != "", m.Template != "", m.Hugo} {
+			if isSet {
+				set++
+			}
+		}
+		switch {
+		case set > 1:
+			return nil, fmt.Errorf("shortcode %s: only one of liquid, template and hugo can be set", name)
+		case set == 0:
+			return nil, fmt.Errorf("shortcode %s: one of liquid, template and hugo must be set", name)
+		case m.Template != "":
+			tmpl, err := template.New(name).Funcs(shortcodeFuncs).Parse(m.Template)
+			if err != nil {
+				return nil, fmt.Errorf("shortcode %s: %w", name, err)
+			}
+			c.templates[name] = tmpl
+		}
+	}
+	return c, nil
+}
+
+func (c *shortcodeConverter) convert(tc TransformContext, src string) (string, error) {
+	lines, err := newShortcodeLines(tc)
+	if err != nil {
+		return "", err
+	}
+	return c.convertBody(tc, src, lines)
+}
+
+func (c *shortcodeConverter) convertBody(tc TransformContext, src string, lines *shortcodeLines) (string, error) {
+	var out strings.Builder
+	pos := 0
+	for {
+		tag, found, err := nextShortcodeTag(src, pos)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			out.WriteString(src[pos:])
+			return out.String(), nil
+		}
+		out.WriteString(src[pos:tag.Start])
+
+		switch {
+		case tag.Escaped:
+			// Like Hugo, {{</* foo */>}} is shown as {{< foo >}}.
+			if src[tag.Start+2] == '<' {
+				out.WriteString("{{< " + tag.Name + " >}}")
+			} else {
+				out.WriteString("{{% " + tag.Name + " %}}")
+			}
+			pos = tag.End
+			continue
+		case tag.Closing:
+			tc.Warnf("line %d: closing shortcode %s without an opening shortcode, leaving it as is",
+				lines.next(tagKey(tag), src, tag.Start), logutil.Yel(tag.Name),
+			)
+			out.WriteString(src[tag.Start:tag.End])
+			pos = tag.End
+			continue
+		}
+
+		sc, err := parseShortcode(src, tag)
+		if err != nil {
+			return "", err
+		}
+		line := lines.next(tagKey(tag), src, tag.Start)
+		switch {
+		case sc.Paired && !c.keepsInner(sc.Name):
+			// The shortcodes nested in the inner content are converted too.
+			sc.Inner, err = c.convertBody(tc, sc.Inner, lines)
+			if err != nil {
+				return "", err
+			}
+		case sc.Paired:
+			lines.skip(sc.Inner)
+		}
+		if sc.Paired {
+			lines.next("/"+sc.Name, src, sc.End)
+		}
+
+		converted, err := c.convertOne(tc, sc, src, line)
+		if err != nil {
+			return "", fmt.Errorf("line %d: shortcode %s: %w", line, sc.Name, err)
+		}
+		out.WriteString(converted)
+		pos = sc.End
+	}
+}
+
+// keepsInner tells whether the inner content of the shortcode is used as is,
+// i.e., when the shortcode is kept as is or rendered by Hugo.
+func (c *shortcodeConverter) keepsInner(name string) bool {
+	m, ok := c.mappings[name]
+	if ok {
+		return m.Hugo
+	}
+	return c.fallback == ShortcodeFallbackKeep || c.fallback == ShortcodeFallbackHugo
+}
+
+// The line is the one of the shortcode in the Markdown file, see
+// shortcodeLines.
+func (c *shortcodeConverter) convertOne(tc TransformContext, sc shortcode, src string, line int) (string, error) {
+	m, ok := c.mappings[sc.Name]
+	if !ok {
+		switch c.fallback {
+		case ShortcodeFallbackError:
+			return "", fmt.Errorf("no mapping for this shortcode, see the shortcodes transformer")
+		case ShortcodeFallbackDrop:
+			tc.Warnf("line %d: no mapping for shortcode %s, dropping it",
+				line, logutil.Yel(sc.Name),
+			)
+			return sc.Inner, nil
+		case ShortcodeFallbackLiquid:
+			tc.Warnf("line %d: no mapping for shortcode %s, converting it to the Liquid tag of the same name",
+				line, logutil.Yel(sc.Name),
+			)
+			m = ShortcodeMapping{Liquid: sc.Name}
+		case ShortcodeFallbackHugo:
+			tc.Warnf("line %d: no mapping for shortcode %s, rendering it with Hugo",
+				line, logutil.Yel(sc.Name),
+			)
+			m = ShortcodeMapping{Hugo: true}
+		default:
+			tc.Warnf("line %d: no mapping for shortcode %s, leaving it as is",
+				line, logutil.Yel(sc.Name),
+			)
+			return src[sc.Start:sc.End], nil
+		}
+	}
+
+	if m.Hugo {
+		return renderWithHugo(tc, src[sc.Start:sc.End])
+	}
+
+	if tmpl, ok := c.templates[sc.Name]; ok {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, sc); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+
+	args := make([]string, 0, len(sc.Params))
+	if len(m.Args) == 0 {
+		for _, p := range sc.Params {
+			args = append(args, p.Value)
+		}
+	}
+	for _, arg := range m.Args {
+		found := false
+		for _, key := range strings.Split(arg, "|") {
+			if v, ok := sc.has(key); ok {
+				args = append(args, v)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("missing param %s", strings.ReplaceAll(arg, "|", " or "))
+		}
+	}
+
+	liquid := "{% " + strings.Join(append([]string{m.Liquid}, args...), " ") + " %}"
+	if sc.Paired {
+		liquid += sc.Inner + "{% end" + m.Liquid + " %}"
+	}
+	return liquid, nil
+}
+
+// renderWithHugo renders the shortcode with the templates of the site. Even
+// though the sites are loaded without rendering anything, the templates are
+// loaded and the page can render a string.
+func renderWithHugo(tc TransformContext, raw string) (string, error) {
+	if tc.Page == nil {
+		return "", fmt.Errorf("cannot render the shortcode with Hugo without a page")
+	}
+	html, err := tc.Page.RenderString(tc.ctx(), raw)
+	if err != nil {
+		return "", fmt.Errorf("while rendering with Hugo: %w", err)
+	}
+
+	// A blank line would end the HTML block in the Markdown, so the blank
+	// lines are removed from the output, except within a <pre> block
+	// where they are part of the content.
+	if strings.Contains(string(html), "<pre") {
+		return strings.TrimRight(string(html), "\n"), nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(html), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
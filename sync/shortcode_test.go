@@ -0,0 +1,240 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_shortcodeConverter(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      ShortcodeOptions
+		given     string
+		expect    string
+		expectErr string
+
+		// expectWarns are the beginnings of the warnings, in order.
+		expectWarns []string
+	}{
+		{
+			name:   "positional param",
+			given:  "{{< youtube 30a0WrfaS2A >}}",
+			expect: "{% youtube 30a0WrfaS2A %}",
+		},
+		{
+			name:   "named param",
+			given:  `{{< youtube id="30a0WrfaS2A" autoplay=true >}}`,
+			expect: "{% youtube 30a0WrfaS2A %}",
+		},
+		{
+			name:   "percent delimiters and no spaces",
+			given:  "{{%youtube 30a0WrfaS2A%}}",
+			expect: "{% youtube 30a0WrfaS2A %}",
+		},
+		{
+			name:   "tweet with user and id",
+			given:  `{{< tweet user="SanDiegoZoo" id="1453110110599868418" >}}`,
+			expect: "{% twitter 1453110110599868418 %}",
+		},
+		{
+			name:   "gist uses a template",
+			given:  "{{< gist spf13 7896402 >}}",
+			expect: "{% gist https://gist.github.com/spf13/7896402 %}",
+		},
+		{
+			name:   "gist with a file",
+			given:  `{{< gist spf13 7896402 "img.html" >}}`,
+			expect: "{% gist https://gist.github.com/spf13/7896402 file=img.html %}",
+		},
+		{
+			name:   "paired liquid tag",
+			given:  "{{< details summary=\"Click me\" >}}\nHidden **content**.\n{{< /details >}}",
+			expect: "{% details Click me %}\nHidden **content**.\n{% enddetails %}",
+		},
+		{
+			name:   "paired shortcode as a code block",
+			given:  "{{< highlight go >}}\nfmt.Println(\"foo\")\n{{< /highlight >}}\n",
+			expect: "```go\nfmt.Println(\"foo\")\n```\n",
+		},
+		{
+			name:   "nested shortcodes",
+			given:  "{{< details Video >}}\n{{< youtube 30a0WrfaS2A >}}\n{{< /details >}}",
+			expect: "{% details Video %}\n{% youtube 30a0WrfaS2A %}\n{% enddetails %}",
+		},
+		{
+			name:   "escaped shortcode",
+			given:  "Use `{{</* youtube 30a0WrfaS2A */>}}`.",
+			expect: "Use `{{< youtube 30a0WrfaS2A >}}`.",
+		},
+		{
+			name:   "unmapped shortcode is kept by default",
+			given:  `{{< my-callout type="warning" >}}Careful!{{< /my-callout >}}`,
+			expect: `{{< my-callout type="warning" >}}Careful!{{< /my-callout >}}`,
+			expectWarns: []string{
+				"line 1: no mapping for shortcode",
+			},
+		},
+		{
+			name:   "unmapped shortcode is dropped but its inner content is kept",
+			opts:   ShortcodeOptions{Fallback: ShortcodeFallbackDrop},
+			given:  "Before {{< my-callout type=\"warning\" >}}Careful!{{< /my-callout >}}\nafter {{< ad >}}.",
+			expect: "Before Careful!\nafter .",
+			expectWarns: []string{
+				"line 1: no mapping for shortcode",
+				"line 2: no mapping for shortcode",
+			},
+		},
+		{
+			name:      "unmapped shortcode is an error",
+			opts:      ShortcodeOptions{Fallback: ShortcodeFallbackError},
+			given:     "Foo\n{{< ad >}}",
+			expectErr: "line 2: shortcode ad: no mapping for this shortcode, see the shortcodes transformer",
+		},
+		{
+			name:   "unmapped shortcode with the liquid fallback",
+			opts:   ShortcodeOptions{Fallback: ShortcodeFallbackLiquid},
+			given:  "{{< codepen abc123 >}}",
+			expect: "{% codepen abc123 %}",
+			expectWarns: []string{
+				"line 1: no mapping for shortcode",
+			},
+		},
+		{
+			name: "custom mappings with param remapping",
+			opts: ShortcodeOptions{Shortcodes: map[string]ShortcodeMapping{
+				"pen":     {Liquid: "codepen", Args: []string{"url"}},
+				"youtube": {Liquid: "embed", Args: []string{"id|0"}},
+				"figure":  {Template: `![{{ .Get "alt" }}]({{ .Get "src" }})`},
+				"note":    {Template: `<aside>{{ .Inner | trim }}</aside>`},
+			}},
+			given:  "{{< pen url=`https://codepen.io/foo` >}} {{< youtube abc >}} {{< figure src=\"a.png\" alt=\"A \\\"nice\\\" one\" >}} {{% note %}}\n Hi \n{{% /note %}}",
+			expect: `{% codepen https://codepen.io/foo %} {% embed abc %} ![A "nice" one](a.png) <aside>Hi</aside>`,
+		},
+		{
+			name:      "missing param",
+			given:     "{{< youtube >}}",
+			expectErr: "line 1: shortcode youtube: missing param id or 0",
+		},
+		{
+			name:      "unterminated shortcode",
+			given:     "Foo\n{{< youtube abc",
+			expectErr: "line 2: unterminated shortcode",
+		},
+		{
+			name:   "double braces that aren't shortcodes",
+			given:  "{{ .Title }} and {% raw %}",
+			expect: "{{ .Title }} and {% raw %}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newShortcodeConverter(tt.opts)
+			require.NoError(t, err)
+			var diags []Diagnostic
+			got, err := c.convert(TransformContext{Path: "post.md", diagnostics: &diags}, tt.given)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+			require.Len(t, diags, len(tt.expectWarns))
+			for i, d := range diags {
+				assert.Equal(t, DiagnosticWarning, d.Level)
+				assert.True(t, strings.HasPrefix(d.Message, tt.expectWarns[i]), "got %q", d.Message)
+			}
+		})
+	}
+}
+
+func Test_newShortcodeConverter(t *testing.T) {
+	_, err := newShortcodeConverter(ShortcodeOptions{Fallback: "foo"})
+	assert.EqualError(t, err, `unknown fallback "foo", expected one of keep, drop, error, liquid or hugo`)
+
+	_, err = newShortcodeConverter(ShortcodeOptions{Shortcodes: map[string]ShortcodeMapping{"foo": {}}})
+	assert.EqualError(t, err, "shortcode foo: one of liquid, template and hugo must be set")
+
+	_, err = newShortcodeConverter(ShortcodeOptions{Shortcodes: map[string]ShortcodeMapping{"foo": {Liquid: "foo", Template: "foo"}}})
+	assert.EqualError(t, err, "shortcode foo: only one of liquid, template and hugo can be set")
+
+	_, err = newShortcodeConverter(ShortcodeOptions{Shortcodes: map[string]ShortcodeMapping{"foo": {Template: "{{ .Foo"}}})
+	assert.ErrorContains(t, err, "shortcode foo: template: foo:1: unclosed action")
+}
+
+// The shortcodes that need the page, i.e., the ones rendered by Hugo and the
+// lines of the shortcodes within the Markdown file.
+func Test_shortcodeConverter_page(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      ShortcodeOptions
+		given     string
+		expect    string
+		expectErr string
+
+		// unwrap runs unwrapSoftBreaks first, like when "unwrap-soft-breaks"
+		// comes first in the config.
+		unwrap bool
+	}{
+		{
+			name: "shortcode rendered by Hugo",
+			opts: ShortcodeOptions{Shortcodes: map[string]ShortcodeMapping{"callout": {Hugo: true}}},
+			given: "{{< callout type=\"warning\" >}}Not *yet*.{{< /callout >}}\n\n" +
+				"{{< figure src=\"setup.png\" >}}\n",
+			// The figure shortcode isn't mapped, so it is left as is.
+			expect: "\n" +
+				`<div class="callout warning">` + "\n" +
+				`Not <em>yet</em>.</div>` + "\n\n" +
+				`{{< figure src="setup.png" >}}` + "\n",
+		},
+		{
+			name: "hugo fallback",
+			opts: ShortcodeOptions{Fallback: ShortcodeFallbackHugo},
+			given: "{{< callout type=\"warning\" >}}Not *yet*.{{< /callout >}}\n\n" +
+				"{{< figure src=\"setup.png\" >}}\n",
+			expect: "\n" +
+				`<div class="callout warning">` + "\n" +
+				`Not <em>yet</em>.</div>` + "\n\n" +
+				`<figure><img src="setup.png">` + "\n" +
+				`</figure>` + "\n",
+		},
+		{
+			name: "the lines are the ones of the file when the paragraphs are unwrapped first",
+			opts: ShortcodeOptions{Fallback: ShortcodeFallbackError},
+			given: "Some\nwrapped text.\n\n" +
+				"{{< details Hi >}}\n{{< youtube abc >}}\n{{< figure src=\"setup.png\" >}}\n{{< /details >}}\n",
+			unwrap:    true,
+			expectErr: "line 12: shortcode figure: no mapping for this shortcode, see the shortcodes transformer",
+		},
+	}
+
+	files := map[string]string{
+		"layouts/shortcodes/callout.html": `<div class="callout {{ .Get "type" }}">` + "\n\n" + `{{ .Inner | .Page.RenderString }}</div>`,
+	}
+	for i, tt := range tests {
+		files[fmt.Sprintf("content/posts/shortcodes-%d.md", i)] = testPost(tt.given)
+	}
+	root, sites := loadTestSite(t, files)
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newShortcodeConverter(tt.opts)
+			require.NoError(t, err)
+			tc := newTestContext(t, root, sites, fmt.Sprintf("content/posts/shortcodes-%d.md", i))
+			body := tc.Page.RawContent()
+			if tt.unwrap {
+				body = unwrapSoftBreaks(body)
+			}
+			got, err := c.convert(tc, body)
+			if tt.expectErr != "" {
+				assert.EqualError(t, err, tt.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+}
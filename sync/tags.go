@@ -0,0 +1,92 @@
+package sync
+
+import (
+	"slices"
+	"strings"
+)
+
+// MaxTags is the maximum number of tags that DEV accepts for an article.
+const MaxTags = 4
+
+// TagRules tells how the keywords of a Hugo post are turned into DEV tags.
+// DEV only accepts up to MaxTags tags made of lowercase letters and digits, so
+// each keyword is first replaced using Aliases and then slugified, e.g.,
+// "Cert-Manager" becomes "certmanager". The duplicate tags are removed and,
+// when more than MaxTags tags remain, the ones listed in Priority are kept
+// first and the others are dropped. See Config for how to set the rules.
+type TagRules struct {
+	// Aliases maps a keyword to the DEV tag used instead, e.g., "kubernetes"
+	// to "k8s". The keys are matched against the lowercased keyword and then
+	// against its slug, so "Kubernetes" and "kuber-netes" both match the key
+	// "kubernetes".
+	Aliases map[string]string `yaml:"aliases"`
+
+	// Priority lists the tags that are kept first when a post has more than
+	// MaxTags tags. The tags that aren't listed keep the order of the
+	// keywords and come after the listed ones.
+	Priority []string `yaml:"priority"`
+}
+
+// apply returns the DEV tags for the given keywords. The dropped keywords are
+// the ones that are left out either because their slug is empty or because
+// the post has too many tags.
+func (r TagRules) apply(keywords []string) (tags, dropped []string) {
+	aliases := make(map[string]string, len(r.Aliases))
+	for from, to := range r.Aliases {
+		aliases[strings.ToLower(strings.TrimSpace(from))] = to
+		aliases[tagSlug(from)] = to
+	}
+
+	// The keyword of each tag is kept so that the dropped tags are shown as
+	// they were written in the front matter.
+	keywordOf := make(map[string]string)
+	for _, keyword := range keywords {
+		tag := tagSlug(keyword)
+		if to, ok := aliases[strings.ToLower(strings.TrimSpace(keyword))]; ok {
+			tag = tagSlug(to)
+		} else if to, ok := aliases[tag]; ok {
+			tag = tagSlug(to)
+		}
+		if tag == "" {
+			dropped = append(dropped, keyword)
+			continue
+		}
+		if _, dup := keywordOf[tag]; dup {
+			continue
+		}
+		keywordOf[tag] = keyword
+		tags = append(tags, tag)
+	}
+
+	if len(tags) <= MaxTags {
+		return tags, dropped
+	}
+
+	rank := func(tag string) int {
+		for i, prio := range r.Priority {
+			if tagSlug(prio) == tag {
+				return i
+			}
+		}
+		return len(r.Priority)
+	}
+	slices.SortStableFunc(tags, func(a, b string) int {
+		return rank(a) - rank(b)
+	})
+	for _, tag := range tags[MaxTags:] {
+		dropped = append(dropped, keywordOf[tag])
+	}
+	return tags[:MaxTags], dropped
+}
+
+// tagSlug lowercases the keyword and removes everything that isn't an ASCII
+// letter or digit since DEV rejects the other characters.
+func tagSlug(keyword string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(keyword) {
+		if ('a' <= r && r <= 'z') || ('0' <= r && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
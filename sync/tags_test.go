@@ -0,0 +1,54 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TagRules_apply(t *testing.T) {
+	tests := []struct {
+		name        string
+		rules       TagRules
+		keywords    []string
+		wantTags    []string
+		wantDropped []string
+	}{
+		{name: "no keywords", keywords: nil, wantTags: nil, wantDropped: nil},
+		{name: "slugifies", keywords: []string{"Go", "Cert-Manager", "web dev"}, wantTags: []string{"go", "certmanager", "webdev"}},
+		{name: "drops the keywords with an empty slug", keywords: []string{"go", "日本語", "--"}, wantTags: []string{"go"}, wantDropped: []string{"日本語", "--"}},
+		{name: "removes duplicates", keywords: []string{"go", "Go", "g-o"}, wantTags: []string{"go"}},
+		{
+			name:     "aliases match the lowercased keyword",
+			rules:    TagRules{Aliases: map[string]string{"c++": "cpp", "Kubernetes": "k8s"}},
+			keywords: []string{"C++", "kubernetes"},
+			wantTags: []string{"cpp", "k8s"},
+		},
+		{
+			name:     "aliases match the slug",
+			rules:    TagRules{Aliases: map[string]string{"kubernetes": "k8s"}},
+			keywords: []string{"Kuber-netes", "k8s"},
+			wantTags: []string{"k8s"},
+		},
+		{
+			name:        "keeps the first four tags",
+			keywords:    []string{"a", "b", "c", "d", "e", "f"},
+			wantTags:    []string{"a", "b", "c", "d"},
+			wantDropped: []string{"e", "f"},
+		},
+		{
+			name:        "keeps the tags with a priority first",
+			rules:       TagRules{Priority: []string{"f", "k8s"}, Aliases: map[string]string{"kubernetes": "k8s"}},
+			keywords:    []string{"a", "b", "c", "Kubernetes", "e", "f"},
+			wantTags:    []string{"f", "k8s", "a", "b"},
+			wantDropped: []string{"c", "e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTags, gotDropped := tt.rules.apply(tt.keywords)
+			assert.Equal(t, tt.wantTags, gotTags)
+			assert.Equal(t, tt.wantDropped, gotDropped)
+		})
+	}
+}
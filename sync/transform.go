@@ -0,0 +1,208 @@
+package sync
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/schollz/closestmatch"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+)
+
+// Hugo follows CommonMark, where a line break within a paragraph is a soft
+// break and is rendered as a space. DEV follows the "Markdown Here"
+// conventions where every line break is a hard break. For example,
+//
+//	We often talk about avoiding unnecessary comments that
+//	needlessly paraphrase what the code does.
+//
+// is rendered by DEV as two lines, so it is converted to:
+//
+//	We often talk about avoiding unnecessary comments that needlessly paraphrase what the code does.
+//
+// Only the lines of the paragraphs (including the ones in list items and
+// block quotes) are joined; code blocks, tables and HTML blocks are left
+// untouched. The body is expected without its front matter, so a leading
+// "---" is a thematic break. The hard breaks, i.e., the lines ending with two
+// spaces or a backslash, are kept. Paragraphs within paired shortcodes such
+// as {{< highlight >}}...{{< /highlight >}} are also kept since the content of
+// the shortcode may not be Markdown.
+func unwrapSoftBreaks(body string) string {
+	src := []byte(body)
+	md := goldmark.New(goldmark.WithExtensions(extension.Table))
+	parsed := md.Parser().Parse(text.NewReader(src))
+	skip := pairedShortcodes(src)
+
+	// The replacements are the ranges of bytes to be replaced with a space,
+	// in the order of the document.
+	var replacements [][2]int
+	_ = ast.Walk(parsed, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch node.(type) {
+		case *ast.Paragraph, *ast.TextBlock:
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		lines := node.Lines()
+		if lines.Len() < 2 {
+			return ast.WalkSkipChildren, nil
+		}
+		first, last := lines.At(0), lines.At(lines.Len()-1)
+		for _, r := range skip {
+			if first.Start < r[1] && r[0] < last.Stop {
+				return ast.WalkSkipChildren, nil
+			}
+		}
+
+		for i := 0; i < lines.Len()-1; i++ {
+			line := src[lines.At(i).Start:lines.At(i).Stop]
+			line = bytes.TrimRight(line, "\r\n")
+			if bytes.HasSuffix(line, []byte("  ")) || bytes.HasSuffix(line, []byte("\\")) {
+				continue
+			}
+			start := lines.At(i).Start + len(bytes.TrimRight(line, " \t"))
+			replacements = append(replacements, [2]int{start, lines.At(i + 1).Start})
+		}
+		return ast.WalkSkipChildren, nil
+	})
+
+	var out strings.Builder
+	prev := 0
+	for _, r := range replacements {
+		out.Write(src[prev:r[0]])
+		out.WriteString(" ")
+		prev = r[1]
+	}
+	out.Write(src[prev:])
+	return out.String()
+}
+
+var shortcodeDelim = regexp.MustCompile(`{{[<%]\s*(/?)([\w-]+)`)
+
+// pairedShortcodes returns the ranges of bytes that go from the start of an
+// opening shortcode to the start of its closing shortcode.
+func pairedShortcodes(src []byte) [][2]int {
+	type opening struct {
+		name  string
+		start int
+	}
+	var stack []opening
+	var ranges [][2]int
+	for _, m := range shortcodeDelim.FindAllSubmatchIndex(src, -1) {
+		name := string(src[m[4]:m[5]])
+		if m[3] == m[2] {
+			stack = append(stack, opening{name: name, start: m[0]})
+			continue
+		}
+		i := -1
+		for j := len(stack) - 1; j >= 0; j-- {
+			if stack[j].name == name {
+				i = j
+				break
+			}
+		}
+		if i == -1 {
+			continue
+		}
+		ranges = append(ranges, [2]int{stack[i].start, m[0]})
+		stack = stack[:i]
+	}
+	return ranges
+}
+
+// The convertAnchorIDs function reads Markdown, finds any anchor-based link of
+// the form [foo](#foo) and converts the GitHub-style anchor IDs to Devto anchor
+// IDs. This is because GitHub-style anchor IDs, which is what Hugo produces,
+// are different from the ones produced by Devto. For example, take the
+// following Markdown:
+//
+//	[`go get -u` vs. `go.mod` (= *_Problem_*)](#go-get--u-vs-gomod--_problem_)
+//
+// becomes
+//
+//	[`go get -u` vs. `go.mod` (= *_Problem_*)](#-raw-go-get-u-endraw-vs-raw-gomod-endraw-problem)
+
+var linkWithOnlyAnchor = regexp.MustCompile(`\[([^\]]*)\]\(#([^\)]*)\)`)
+var code = regexp.MustCompile("`([^`]*)`")
+var whitespace = regexp.MustCompile(`\s+`)
+var nonAlphaNumExceptDashAndSpace = regexp.MustCompile(`[^-a-zA-Z0-9]`)
+var multipleDashes = regexp.MustCompile(`-{2,}`)
+
+// only ATX headings are supported (headings of the form "# Title")
+func convertAnchorIDs(tc TransformContext, in string, sanitizeAnchorName func(s string) string) string {
+	inBytes := []byte(in)
+	parsed := goldmark.DefaultParser().Parse(text.NewReader(inBytes))
+
+	anchorToHeading := make(map[string]string)
+	ast.Walk(parsed, func(node ast.Node, _ bool) (ast.WalkStatus, error) {
+		headingNode, ok := node.(*ast.Heading)
+		if ok {
+			if headingNode.Lines().Len() != 1 {
+				tc.Errorf("unexpected heading: %s", headingNode.Text(inBytes))
+				return ast.WalkContinue, nil
+			}
+			seg := headingNode.Lines().At(0)
+			heading := string(seg.Value(inBytes))
+
+			anchorToHeading[sanitizeAnchorName(heading)] = heading
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return linkWithOnlyAnchor.ReplaceAllStringFunc(in, func(s string) string {
+		matches := linkWithOnlyAnchor.FindStringSubmatch(s)
+		if len(matches) != 3 {
+			return s
+		}
+
+		// We ignore the "text" part, since we will use the headings that we
+		// found when parsing the Markdown document.
+		//
+		//  [`go get -u` vs. `go.mod` (= *_problem_*)](#go-get--u-vs-gomod--_problem_)
+		//   <-------------------------------------->  <-------------------------->
+		//                text is ignored                         anchor
+		anchor := matches[2]
+		heading, found := anchorToHeading[anchor]
+		if !found {
+			possibleAnchors := make([]string, 0, len(anchorToHeading))
+			for anchor := range anchorToHeading {
+				possibleAnchors = append(possibleAnchors, anchor)
+			}
+			matcher := closestmatch.New(possibleAnchors, []int{2})
+
+			tc.Errorf("anchor %q in link %s doesn't exist in the document. Did you mean %s?",
+				anchor, s,
+				matcher.Closest(anchor),
+			)
+
+			return s
+		}
+
+		// Replace the anchor ID.
+		return strings.Replace(s, "#"+matches[2], "#"+devtoAnchorID(heading), 1)
+	})
+}
+
+// devtoAnchorID returns the anchor ID that DEV gives to the heading.
+func devtoAnchorID(heading string) string {
+	// Rule 1: `foo` is converted to `raw-foo-endraw-`.
+	heading = code.ReplaceAllString(heading, "-raw-$1-endraw-")
+
+	// Rule 2: whitespaces (spaces and tabs) are replaced with a dash (-).
+	heading = whitespace.ReplaceAllString(heading, "-")
+
+	// Rule 3: all other non-alphanumeric characters are removed.
+	heading = nonAlphaNumExceptDashAndSpace.ReplaceAllString(heading, "")
+
+	// Rule 4: two dashes or more are combined into a single dash.
+	heading = multipleDashes.ReplaceAllString(heading, "-")
+
+	// Rule 5: the anchor ID is lowercase.
+	return strings.ToLower(heading)
+}
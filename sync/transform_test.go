@@ -0,0 +1,186 @@
+package sync
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_convertAnchorIDs(t *testing.T) {
+	tests := []struct {
+		// I initially wanted to use Hugo's SanitizeAnchorName, but it would
+		// require a complicated setup. Instead, I pretend that I'm Hugo and I
+		// do the conversion myself.
+		headingToAnchor map[string]string
+		given, expect   string
+	}{
+		{
+			headingToAnchor: map[string]string{"`go get -u` vs. `go.mod` (= *_problem_*)": "go-get--u-vs-gomod--_problem_"},
+			given: "# `go get -u` vs. `go.mod` (= *_problem_*)\n" +
+				"[`go get -u` vs. `go.mod` (= *_problem_*)](#go-get--u-vs-gomod--_problem_)\n",
+			expect: "# `go get -u` vs. `go.mod` (= *_problem_*)\n" +
+				"[`go get -u` vs. `go.mod` (= *_problem_*)](#-raw-go-get-u-endraw-vs-raw-gomod-endraw-problem)\n",
+		},
+		{
+			// The closing endraw is always followed by a dash.
+			headingToAnchor: map[string]string{"`foo`": "foo"},
+			given: "# `foo`\n" +
+				"[`foo`](#foo)\n",
+			expect: "# `foo`\n" +
+				"[`foo`](#-raw-foo-endraw-)\n",
+		},
+		{
+			// Upper case letters are converted to lower case.
+			headingToAnchor: map[string]string{"Title Is CAPITAL": "title-is-capital"},
+			given: "### Title Is CAPITAL\n" +
+				"[Title Is CAPITAL](#title-is-capital)\n",
+			expect: "### Title Is CAPITAL\n" +
+				"[Title Is CAPITAL](#title-is-capital)\n",
+		},
+		{
+			// It should not affect the anchor links that were manually created.
+			headingToAnchor: map[string]string{"Docker proxy vs. Local registry": "docker-proxy-vs-local-registry"},
+			given: "## Docker proxy vs. Local registry\n" +
+				"[see this section](#docker-proxy-vs-local-registry)\n",
+			expect: "## Docker proxy vs. Local registry\n" +
+				"[see this section](#docker-proxy-vs-local-registry)\n",
+		},
+	}
+	for i, tt := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			assert.Equal(t, tt.expect, convertAnchorIDs(TransformContext{Path: "path/to/file.md"}, tt.given, func(s string) string {
+				return tt.headingToAnchor[s]
+			}))
+		})
+	}
+}
+
+func Test_unwrapSoftBreaks(t *testing.T) {
+	tests := []struct {
+		name          string
+		given, expect string
+	}{
+		{
+			name: "paragraph",
+			given: "We often talk about avoiding\n" +
+				"unnecessary comments.\n" +
+				"\n" +
+				"Second paragraph\n" +
+				"on two lines.\n",
+			expect: "We often talk about avoiding unnecessary comments.\n" +
+				"\n" +
+				"Second paragraph on two lines.\n",
+		},
+		{
+			name:   "trailing spaces before the soft break are removed",
+			given:  "Foo \t\nbar\n",
+			expect: "Foo bar\n",
+		},
+		{
+			name:   "hard breaks are kept",
+			given:  "Foo  \nbar\\\nbaz\nqux\n",
+			expect: "Foo  \nbar\\\nbaz qux\n",
+		},
+		{
+			name: "list items",
+			given: "- First item\n" +
+				"  continued.\n" +
+				"- Second item\n" +
+				"\n" +
+				"  with a second paragraph\n" +
+				"  continued.\n",
+			expect: "- First item continued.\n" +
+				"- Second item\n" +
+				"\n" +
+				"  with a second paragraph continued.\n",
+		},
+		{
+			name:   "block quotes",
+			given:  "> Foo\n> bar.\n",
+			expect: "> Foo bar.\n",
+		},
+		{
+			name: "code blocks are left untouched",
+			given: "```go\n" +
+				"fmt.Println(\n" +
+				"\t\"foo\")\n" +
+				"```\n" +
+				"\n" +
+				"    indented\n" +
+				"    code\n",
+			expect: "```go\n" +
+				"fmt.Println(\n" +
+				"\t\"foo\")\n" +
+				"```\n" +
+				"\n" +
+				"    indented\n" +
+				"    code\n",
+		},
+		{
+			name: "tables are left untouched",
+			given: "| a | b |\n" +
+				"|---|---|\n" +
+				"| 1 | 2 |\n",
+			expect: "| a | b |\n" +
+				"|---|---|\n" +
+				"| 1 | 2 |\n",
+		},
+		{
+			name: "HTML blocks are left untouched",
+			given: "<div>\n" +
+				"foo\n" +
+				"bar\n" +
+				"</div>\n",
+			expect: "<div>\n" +
+				"foo\n" +
+				"bar\n" +
+				"</div>\n",
+		},
+		{
+			name: "leading thematic break",
+			given: "---\n" +
+				"\n" +
+				"Some text\n" +
+				"wrapped\n" +
+				"\n" +
+				"---\n" +
+				"\n" +
+				"more\n" +
+				"text\n",
+			expect: "---\n" +
+				"\n" +
+				"Some text wrapped\n" +
+				"\n" +
+				"---\n" +
+				"\n" +
+				"more text\n",
+		},
+		{
+			name: "paired shortcodes are left untouched",
+			given: "{{< highlight go >}}\n" +
+				"foo\n" +
+				"bar\n" +
+				"{{< /highlight >}}\n" +
+				"\n" +
+				"Foo\n" +
+				"{{< youtube 30a0WrfaS2A >}}\n",
+			expect: "{{< highlight go >}}\n" +
+				"foo\n" +
+				"bar\n" +
+				"{{< /highlight >}}\n" +
+				"\n" +
+				"Foo {{< youtube 30a0WrfaS2A >}}\n",
+		},
+		{
+			name:   "inline code and links spanning two lines",
+			given:  "See [the\ndocs](https://example.com) and `go\ntest`.\n",
+			expect: "See [the docs](https://example.com) and `go test`.\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, unwrapSoftBreaks(tt.given))
+		})
+	}
+}
@@ -0,0 +1,481 @@
+package sync
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	gosync "sync"
+
+	"github.com/gohugoio/hugo/resources/page"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"golang.org/x/net/html"
+
+	"github.com/maelvls/hudevto/logutil"
+)
+
+// rewriteURLs changes the URLs of the links and images of the body using the
+// rewrite func, which returns false when a URL must be left as is.
+func rewriteURLs(body string, rewrite func(ref string) (string, bool)) string {
+	src := []byte(body)
+	var edits []urlEdit
+	for _, ref := range findURLs(src) {
+		if resolved, ok := rewrite(ref.url); ok {
+			edits = append(edits, urlEdit{start: ref.start, stop: ref.stop, url: resolved})
+		}
+	}
+	return applyURLEdits(src, edits)
+}
+
+// urlRef is a URL found in a post, see findURLs.
+type urlRef struct {
+	start, stop int // Where the URL is in the source.
+	url         string
+
+	// image is true when the URL is the source of an image, e.g., ![](a.png)
+	// or <img src="a.png">, rather than the destination of a link.
+	image bool
+}
+
+// findURLs returns the URLs of the links and images of the body.
+//
+// The Markdown is parsed so that the links and images that span several
+// lines and the reference definitions are found, and so that the code blocks
+// and code spans are left out. The HTML, whether it is a block or inline, is
+// tokenized and only the src, srcset, href and poster attributes of the tags
+// are looked at, which includes the <source> tags of a <picture>.
+func findURLs(src []byte) []urlRef {
+	md := goldmark.New(goldmark.WithExtensions(extension.Table))
+	doc := md.Parser().Parse(text.NewReader(src))
+
+	var refs []urlRef
+
+	// The reference definitions aren't part of the AST, they are looked up
+	// with a regex outside of the code and HTML blocks.
+	var skip [][2]int
+	_ = ast.Walk(doc, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := node.(type) {
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			start, stop := linesRange(n.Lines())
+			skip = append(skip, [2]int{start, stop})
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock:
+			start, stop := linesRange(n.Lines())
+			if n.HasClosure() {
+				stop = max(stop, n.ClosureLine.Stop)
+			}
+			skip = append(skip, [2]int{start, stop})
+			refs = append(refs, htmlURLs(src, start, stop)...)
+			return ast.WalkSkipChildren, nil
+		case *ast.Paragraph, *ast.TextBlock, *ast.Heading, *extast.TableCell:
+			refs = append(refs, inlineURLs(src, n)...)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	// Since a reference definition may be used by both links and images, it
+	// is only seen as an image when it looks like one.
+	for _, m := range linkRefDef.FindAllSubmatchIndex(src, -1) {
+		if inRanges(skip, m[0]) {
+			continue
+		}
+		start, stop := m[2], m[3]
+		if src[start] == '<' {
+			start, stop = start+1, stop-1
+		}
+		ref := string(src[start:stop])
+		refs = append(refs, urlRef{start: start, stop: stop, url: ref, image: isImagePath(ref)})
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].start < refs[j].start })
+	return refs
+}
+
+// isImagePath tells whether the path of the URL has the extension of an
+// image, e.g., .png or .svg.
+func isImagePath(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mime.TypeByExtension(strings.ToLower(path.Ext(u.Path))), "image/")
+}
+
+// A link reference definition, e.g., `[logo]: ./logo.png "The logo"`.
+var linkRefDef = regexp.MustCompile(`(?m)^[ \t>]*\[[^\]\n]+\]:[ \t]*\n?[ \t>]*(<[^>\n]*>|\S+)`)
+
+// The relative links and images of a post only work on the blog, so they are
+// resolved against the post's URL before pushing to DEV. For example, with the
+// post https://maelvls.dev/you-should-write-comments/,
+//
+//	![My image](cover.png)
+//	[Previous post](../writing-useful-comments/)
+//	<img src="/images/logo.svg">
+//
+// become:
+//
+//	![My image](https://maelvls.dev/you-should-write-comments/cover.png)
+//	[Previous post](https://maelvls.dev/writing-useful-comments/)
+//	<img src="https://maelvls.dev/images/logo.svg">
+//
+// resolveURL returns false for the URLs that are left untouched: the absolute
+// ones, e.g., https://..., the ones that only have an anchor, e.g., #foo, and
+// the ones that contain a Hugo shortcode.
+func resolveURL(base *url.URL, ref string) (string, bool) {
+	switch {
+	case base == nil, ref == "":
+		return "", false
+	case strings.HasPrefix(ref, "#"), strings.HasPrefix(ref, "//"):
+		return "", false
+	case strings.Contains(ref, "{{"):
+		return "", false
+	}
+	u, err := url.Parse(ref)
+	if err != nil || u.IsAbs() {
+		return "", false
+	}
+	return base.ResolveReference(u).String(), true
+}
+
+type urlEdit struct {
+	start, stop int
+	url         string
+}
+
+func applyURLEdits(src []byte, edits []urlEdit) string {
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var out strings.Builder
+	prev := 0
+	for _, e := range edits {
+		if e.start < prev {
+			continue
+		}
+		out.Write(src[prev:e.start])
+		out.WriteString(e.url)
+		prev = e.stop
+	}
+	out.Write(src[prev:])
+	return out.String()
+}
+
+func linesRange(lines *text.Segments) (int, int) {
+	if lines.Len() == 0 {
+		return 0, 0
+	}
+	return lines.At(0).Start, lines.At(lines.Len() - 1).Stop
+}
+
+func inRanges(ranges [][2]int, pos int) bool {
+	for _, r := range ranges {
+		if r[0] <= pos && pos < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// inlineURLs finds the destinations of the links and images of a block as
+// well as the URLs in its inline HTML. Since goldmark doesn't record where the
+// destinations are, each destination is read from the source right after the
+// text of its link.
+func inlineURLs(src []byte, block ast.Node) []urlRef {
+	start, end := linesRange(block.Lines())
+	if start == end {
+		return nil
+	}
+
+	// The cursor is where the source of the block has been read up to.
+	cursor := start
+	var refs []urlRef
+	_ = ast.Walk(block, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := node.(type) {
+		case *ast.Text:
+			if entering {
+				cursor = max(cursor, n.Segment.Stop)
+			}
+		case *ast.RawHTML:
+			if entering && n.Segments.Len() > 0 {
+				htmlStart, htmlStop := linesRange(n.Segments)
+				refs = append(refs, htmlURLs(src, htmlStart, htmlStop)...)
+				cursor = max(cursor, htmlStop)
+			}
+		case *ast.Link, *ast.Image:
+			// The text of a link may contain an image, e.g., [![alt](a.png)](b),
+			// so the destination is read once the text has been visited.
+			if entering {
+				return ast.WalkContinue, nil
+			}
+			destStart, destStop, linkEnd, ok := inlineDestination(src, cursor, end)
+			if !ok {
+				return ast.WalkContinue, nil
+			}
+			cursor = linkEnd
+			_, image := n.(*ast.Image)
+			refs = append(refs, urlRef{start: destStart, stop: destStop, url: string(src[destStart:destStop]), image: image})
+		}
+		return ast.WalkContinue, nil
+	})
+	return refs
+}
+
+// inlineDestination reads the "](destination "title")" that closes a link
+// whose text ends at pos. Only the emphasis and code span markers as well as
+// the opening "![" of a link with an empty text may come between pos and the
+// closing bracket. It returns where the destination is and where the link
+// ends.
+func inlineDestination(src []byte, pos, end int) (destStart, destStop, linkEnd int, ok bool) {
+	i := pos
+	for i < end && strings.IndexByte("*_~` \t\r\n![", src[i]) >= 0 {
+		i++
+	}
+	if !bytes.HasPrefix(src[i:end], []byte("](")) {
+		return 0, 0, 0, false
+	}
+	i += len("](")
+	i = skipSpaces(src, i, end)
+
+	if i < end && src[i] == '<' {
+		destStart = i + 1
+		closing := bytes.IndexByte(src[destStart:end], '>')
+		if closing < 0 {
+			return 0, 0, 0, false
+		}
+		destStop = destStart + closing
+		i = destStop + 1
+	} else {
+		destStart = i
+		depth := 0
+	loop:
+		for ; i < end; i++ {
+			switch src[i] {
+			case '\\':
+				i++
+			case '(':
+				depth++
+			case ')':
+				if depth == 0 {
+					break loop
+				}
+				depth--
+			case ' ', '\t', '\r', '\n':
+				break loop
+			}
+		}
+		destStop = min(i, end)
+	}
+
+	// The optional title, e.g., "title", 'title' or (title).
+	i = skipSpaces(src, i, end)
+	if i < end && strings.IndexByte(`"'(`, src[i]) >= 0 {
+		closing := map[byte]byte{'"': '"', '\'': '\'', '(': ')'}[src[i]]
+		for i++; i < end && src[i] != closing; i++ {
+			if src[i] == '\\' {
+				i++
+			}
+		}
+		i = skipSpaces(src, i+1, end)
+	}
+	if i >= end || src[i] != ')' {
+		return 0, 0, 0, false
+	}
+	return destStart, destStop, i + 1, true
+}
+
+func skipSpaces(src []byte, i, end int) int {
+	for i < end && strings.IndexByte(" \t\r\n", src[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+// The attributes whose value is a URL, except srcset which is a list of URLs.
+var htmlURLAttrs = map[string]bool{"src": true, "href": true, "poster": true}
+
+// An attribute within a tag, e.g., ` src="foo.png"`. The value is in one of
+// the groups 2, 3 or 4 depending on how it is quoted.
+var htmlAttr = regexp.MustCompile(`\s([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'=<>` + "`" + `]+)))?`)
+
+// htmlURLs finds the URLs in the attributes of the tags found in
+// src[start:stop]. The tokenizer is used to find the tags so that the text,
+// the comments and the content of <script> are left alone.
+func htmlURLs(src []byte, start, stop int) []urlRef {
+	var refs []urlRef
+	z := html.NewTokenizer(bytes.NewReader(src[start:stop]))
+	pos := start
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return refs
+		}
+		raw := z.Raw()
+		tagStart := pos
+		pos += len(raw)
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		tag, _ := z.TagName()
+
+		for _, m := range htmlAttr.FindAllSubmatchIndex(raw, -1) {
+			name := strings.ToLower(string(raw[m[2]:m[3]]))
+			if !htmlURLAttrs[name] && name != "srcset" {
+				continue
+			}
+			var valStart, valStop int
+			switch {
+			case m[4] >= 0:
+				valStart, valStop = m[4], m[5]
+			case m[6] >= 0:
+				valStart, valStop = m[6], m[7]
+			case m[8] >= 0:
+				valStart, valStop = m[8], m[9]
+			default:
+				continue
+			}
+
+			image := name == "srcset" || name == "poster" || (name == "src" && string(tag) == "img")
+			if name != "srcset" {
+				refs = append(refs, urlRef{start: tagStart + valStart, stop: tagStart + valStop, url: string(raw[valStart:valStop]), image: image})
+				continue
+			}
+			for _, c := range srcsetURLs(string(raw[valStart:valStop])) {
+				refs = append(refs, urlRef{start: tagStart + valStart + c[0], stop: tagStart + valStart + c[1], url: string(raw[valStart+c[0] : valStart+c[1]]), image: image})
+			}
+		}
+	}
+}
+
+// srcsetURLs returns where the URLs of a srcset are, e.g.,
+//
+//	small.webp 480w, large.webp 1080w
+func srcsetURLs(srcset string) [][2]int {
+	var urls [][2]int
+	offset := 0
+	for _, candidate := range strings.Split(srcset, ",") {
+		lead := len(candidate) - len(strings.TrimLeft(candidate, " \t\r\n"))
+		urlEnd := strings.IndexAny(candidate[lead:], " \t\r\n")
+		if urlEnd < 0 {
+			urlEnd = len(candidate)
+		} else {
+			urlEnd += lead
+		}
+		if urlEnd > lead {
+			urls = append(urls, [2]int{offset + lead, offset + urlEnd})
+		}
+		offset += len(candidate) + len(",")
+	}
+	return urls
+}
+
+// What the links to the other posts of the blog point to, see URLOptions.
+const (
+	// The links point to the posts on the blog.
+	LinksBlog = "blog"
+	// The links point to the DEV articles of the posts that are published on
+	// DEV, and to the blog for the other posts.
+	LinksDevto = "devto"
+)
+
+// URLOptions are the params of the "absolute-urls" transformer.
+type URLOptions struct {
+	// Links is one of the Links* values. It tells where the links to the
+	// other posts of the blog point to, whether they are relative, e.g.,
+	// ../other-post/, or absolute, e.g., https://maelvls.dev/other-post/.
+	// Defaults to LinksBlog.
+	Links string `yaml:"links"`
+}
+
+// urlRewriter is the "absolute-urls" transformer.
+type urlRewriter struct {
+	links string
+
+	// The posts of the site by their normalized permalink. Loaded on first
+	// use since the sites are the same for all the posts.
+	postsOnce gosync.Once
+	posts     map[string]page.Page
+}
+
+func newURLRewriter(opts URLOptions) (*urlRewriter, error) {
+	switch opts.Links {
+	case "":
+		opts.Links = LinksBlog
+	case LinksBlog, LinksDevto:
+	default:
+		return nil, fmt.Errorf("unknown links %q, expected one of blog or devto", opts.Links)
+	}
+	return &urlRewriter{links: opts.Links}, nil
+}
+
+func (r *urlRewriter) transform(tc TransformContext, body string) (string, error) {
+	base, err := url.Parse(tc.Page.Permalink())
+	if err != nil {
+		return "", fmt.Errorf("while parsing the permalink of the post: %w", err)
+	}
+	return rewriteURLs(body, func(ref string) (string, bool) {
+		resolved, ok := resolveURL(base, ref)
+		if !ok {
+			resolved = ref
+		}
+		if r.links == LinksDevto {
+			if devtoURL, found := r.devtoURL(tc, resolved); found {
+				return devtoURL, true
+			}
+		}
+		return resolved, ok
+	}), nil
+}
+
+// devtoURL returns the URL of the DEV article of the post that the link points
+// to. It returns false when the link doesn't point to one of the posts or
+// when the post isn't published on DEV.
+func (r *urlRewriter) devtoURL(tc TransformContext, link string) (string, bool) {
+	u, err := url.Parse(link)
+	if err != nil || !u.IsAbs() {
+		return "", false
+	}
+	fragment := u.Fragment
+	u.Fragment, u.RawFragment, u.RawQuery = "", "", ""
+
+	r.postsOnce.Do(func() {
+		r.posts = make(map[string]page.Page)
+		if tc.Sites == nil {
+			return
+		}
+		for _, pg := range tc.Sites.Pages() {
+			if pg.Kind() == "page" {
+				r.posts[normalizeURL(pg.Permalink())] = pg
+			}
+		}
+	})
+	pg, ok := r.posts[normalizeURL(u.String())]
+	if !ok {
+		return "", false
+	}
+
+	devtoID, _ := pg.Param("devtoId")
+	id, ok := intParam(devtoID)
+	if !ok {
+		return "", false
+	}
+	art, ok := tc.Articles[id]
+	if !ok || !art.Published || art.URL == nil || art.URL.URL == nil {
+		logutil.Debugf("%s: the link to %s is kept since %s isn't published on DEV",
+			logutil.Gray(tc.Path), link, logutil.Gray(pg.Path()),
+		)
+		return "", false
+	}
+	devtoURL := *art.URL.URL
+	devtoURL.Fragment = fragment
+	return devtoURL.String(), true
+}
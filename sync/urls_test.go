@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/VictorAvelar/devto-api-go/devto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_rewriteURLs(t *testing.T) {
+	tests := []struct {
+		name          string
+		given, expect string
+	}{
+		{
+			name:   "relative image and link",
+			given:  "![My image](cover.png) and [the code](./main.go).\n",
+			expect: "![My image](https://maelvls.dev/you-should-write-comments/cover.png) and [the code](https://maelvls.dev/you-should-write-comments/main.go).\n",
+		},
+		{
+			name:   "root-relative and sibling post",
+			given:  "![Logo](/images/logo.svg) [Previous post](../writing-useful-comments/#intro)\n",
+			expect: "![Logo](https://maelvls.dev/images/logo.svg) [Previous post](https://maelvls.dev/writing-useful-comments/#intro)\n",
+		},
+		{
+			name: "multi-line image with a title",
+			given: "![A long\n" +
+				"alt text](\n" +
+				"  diagram.webp \"The diagram\")\n",
+			expect: "![A long\n" +
+				"alt text](\n" +
+				"  https://maelvls.dev/you-should-write-comments/diagram.webp \"The diagram\")\n",
+		},
+		{
+			name:   "image within a link and emphasis within the text",
+			given:  "[![**Build**](badge.avif)](<ci runs/>) [`go get`](go-get)\n",
+			expect: "[![**Build**](https://maelvls.dev/you-should-write-comments/badge.avif)](<https://maelvls.dev/you-should-write-comments/ci%20runs/>) [`go get`](https://maelvls.dev/you-should-write-comments/go-get)\n",
+		},
+		{
+			name: "reference-style image",
+			given: "![Cover][cover] and [home][].\n" +
+				"\n" +
+				"[cover]: cover.jpg \"Cover\"\n" +
+				"[home]: <../>\n",
+			expect: "![Cover][cover] and [home][].\n" +
+				"\n" +
+				"[cover]: https://maelvls.dev/you-should-write-comments/cover.jpg \"Cover\"\n" +
+				"[home]: <https://maelvls.dev/>\n",
+		},
+		{
+			name: "absolute URLs, anchors and shortcodes are left untouched",
+			given: "[Go](https://go.dev) [CDN](//cdn.example.com/a.png) [Top](#top)\n" +
+				"[Mail](mailto:foo@example.com) [Ref]({{< ref \"other.md\" >}})\n" +
+				"<img src=\"https://example.com/a.png\">\n",
+			expect: "[Go](https://go.dev) [CDN](//cdn.example.com/a.png) [Top](#top)\n" +
+				"[Mail](mailto:foo@example.com) [Ref]({{< ref \"other.md\" >}})\n" +
+				"<img src=\"https://example.com/a.png\">\n",
+		},
+		{
+			name: "code spans and code blocks are left untouched",
+			given: "Write `![img](a.png)` to show an image.\n" +
+				"\n" +
+				"```markdown\n" +
+				"![img](a.png)\n" +
+				"[ref]: a.png\n" +
+				"<img src=\"a.png\">\n" +
+				"```\n",
+			expect: "Write `![img](a.png)` to show an image.\n" +
+				"\n" +
+				"```markdown\n" +
+				"![img](a.png)\n" +
+				"[ref]: a.png\n" +
+				"<img src=\"a.png\">\n" +
+				"```\n",
+		},
+		{
+			name: "HTML blocks",
+			given: "<img alt=\"Super example\" src=\"dnat.svg\" width=\"80%\"/>\n" +
+				"\n" +
+				"<picture>\n" +
+				"  <source srcset=\"small.webp 480w, /big.webp 1080w\" type=\"image/webp\">\n" +
+				"  <img src='fallback.png'>\n" +
+				"</picture>\n" +
+				"\n" +
+				"<video poster=demo.jpg><source src=\"demo.mp4\"></video>\n",
+			expect: "<img alt=\"Super example\" src=\"https://maelvls.dev/you-should-write-comments/dnat.svg\" width=\"80%\"/>\n" +
+				"\n" +
+				"<picture>\n" +
+				"  <source srcset=\"https://maelvls.dev/you-should-write-comments/small.webp 480w, https://maelvls.dev/big.webp 1080w\" type=\"image/webp\">\n" +
+				"  <img src='https://maelvls.dev/you-should-write-comments/fallback.png'>\n" +
+				"</picture>\n" +
+				"\n" +
+				"<video poster=https://maelvls.dev/you-should-write-comments/demo.jpg><source src=\"https://maelvls.dev/you-should-write-comments/demo.mp4\"></video>\n",
+		},
+		{
+			name:   "inline HTML",
+			given:  "See <a href=\"../other/\">the other post</a>, data-src=\"a.png\" is text.\n",
+			expect: "See <a href=\"https://maelvls.dev/other/\">the other post</a>, data-src=\"a.png\" is text.\n",
+		},
+		{
+			name:   "tables",
+			given:  "| Image |\n| --- |\n| ![a](a.gif) |\n",
+			expect: "| Image |\n| --- |\n| ![a](https://maelvls.dev/you-should-write-comments/a.gif) |\n",
+		},
+	}
+	base, err := url.Parse("https://maelvls.dev/you-should-write-comments/")
+	require.NoError(t, err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expect, rewriteURLs(tt.given, func(ref string) (string, bool) {
+				return resolveURL(base, ref)
+			}))
+		})
+	}
+}
+
+func Test_urlRewriter(t *testing.T) {
+	const devtoURL = "https://dev.to/maelvls/published-post-1001"
+	tests := []struct {
+		links  string
+		expect string
+	}{
+		{
+			links: LinksBlog,
+			expect: "\n" +
+				"See [the published post](https://blog.example.com/posts/published/#intro),\n" +
+				"[the same post](https://blog.example.com/posts/published/),\n" +
+				"[again](https://blog.example.com/posts/published/),\n" +
+				"[the unpublished one](https://blog.example.com/posts/with-image/) and [Go](https://go.dev/).\n",
+		},
+		{
+			links: LinksDevto,
+			expect: "\n" +
+				"See [the published post](" + devtoURL + "#intro),\n" +
+				"[the same post](" + devtoURL + "),\n" +
+				"[again](" + devtoURL + "),\n" +
+				"[the unpublished one](https://blog.example.com/posts/with-image/) and [Go](https://go.dev/).\n",
+		},
+	}
+
+	root, sites := loadTestSite(t, map[string]string{
+		"content/posts/links.md": testPost("" +
+			"See [the published post](../published/#intro),\n" +
+			"[the same post](https://blog.example.com/posts/published/),\n" +
+			`[again]({{< ref "published.md" >}}),` + "\n" +
+			"[the unpublished one](/posts/with-image/) and [Go](https://go.dev/).\n"),
+	})
+	tc := newTestContext(t, root, sites, "content/posts/links.md")
+	tc.Articles = map[int]*devto.ListedArticle{
+		1001: {ID: 1001, Published: true, URL: webURL(t, devtoURL)},
+		1002: {ID: 1002, URL: webURL(t, "https://dev.to/maelvls/post-with-an-image-1002-temp-slug")},
+	}
+	body, err := resolveRefs(tc, tc.Page.RawContent())
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.links, func(t *testing.T) {
+			r, err := newURLRewriter(URLOptions{Links: tt.links})
+			require.NoError(t, err)
+			got, err := r.transform(tc, body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expect, got)
+		})
+	}
+
+	t.Run("unknown value", func(t *testing.T) {
+		_, err := newURLRewriter(URLOptions{Links: "foo"})
+		assert.EqualError(t, err, `unknown links "foo", expected one of blog or devto`)
+	})
+}